@@ -0,0 +1,182 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/FelipeGeraldoblufus/Cart/metrics"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"gorm.io/gorm"
+)
+
+// Code identifica el tipo de error de forma estable, para que los clientes
+// puedan decidir según el código y no según el texto del mensaje.
+type Code string
+
+const (
+	CodeInvalidRequest      Code = "INVALID_REQUEST"
+	CodeUnauthorized        Code = "UNAUTHORIZED"
+	CodeForbidden           Code = "FORBIDDEN"
+	CodeNotFound            Code = "NOT_FOUND"
+	CodeUnknownPattern      Code = "UNKNOWN_PATTERN"
+	CodeProductNotFound     Code = "PRODUCT_NOT_FOUND"
+	CodeDuplicateProduct    Code = "DUPLICATE_PRODUCT"
+	CodeDuplicateSKU        Code = "DUPLICATE_SKU"
+	CodeInvalidProduct      Code = "INVALID_PRODUCT"
+	CodeProductInactive     Code = "PRODUCT_INACTIVE"
+	CodeUserNotFound        Code = "USER_NOT_FOUND"
+	CodeDuplicateUsername   Code = "DUPLICATE_USERNAME"
+	CodeCartItemNotFound    Code = "CART_ITEM_NOT_FOUND"
+	CodeEmptyCart           Code = "EMPTY_CART"
+	CodeCartChanged         Code = "CART_CHANGED"
+	CodeMixedCurrency       Code = "MIXED_CURRENCY"
+	CodeInvalidQuantity     Code = "INVALID_QUANTITY"
+	CodeInsufficientStock   Code = "INSUFFICIENT_STOCK"
+	CodeInvalidStock        Code = "INVALID_STOCK"
+	CodeOrderNotFound       Code = "ORDER_NOT_FOUND"
+	CodeOrderUserMismatch   Code = "ORDER_USER_MISMATCH"
+	CodeInvalidOrderStatus  Code = "INVALID_ORDER_STATUS"
+	CodeInvalidTransition   Code = "INVALID_STATUS_TRANSITION"
+	CodeIdempotencyMismatch Code = "IDEMPOTENCY_KEY_MISMATCH"
+	CodeIdempotencyInFlight Code = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	CodeDeadLetterNotFound  Code = "DEAD_LETTER_NOT_FOUND"
+	CodeUnavailable         Code = "SERVICE_UNAVAILABLE"
+	CodeInternal            Code = "INTERNAL_ERROR"
+)
+
+// statuses asigna a cada código su estado HTTP. Los códigos que no están
+// aquí responden 500.
+var statuses = map[Code]int{
+	CodeInvalidRequest:      http.StatusBadRequest,
+	CodeUnauthorized:        http.StatusUnauthorized,
+	CodeForbidden:           http.StatusForbidden,
+	CodeNotFound:            http.StatusNotFound,
+	CodeUnknownPattern:      http.StatusNotFound,
+	CodeProductNotFound:     http.StatusNotFound,
+	CodeDuplicateProduct:    http.StatusConflict,
+	CodeDuplicateSKU:        http.StatusConflict,
+	CodeInvalidProduct:      http.StatusBadRequest,
+	CodeProductInactive:     http.StatusUnprocessableEntity,
+	CodeUserNotFound:        http.StatusNotFound,
+	CodeDuplicateUsername:   http.StatusConflict,
+	CodeCartItemNotFound:    http.StatusNotFound,
+	CodeEmptyCart:           http.StatusBadRequest,
+	CodeCartChanged:         http.StatusConflict,
+	CodeMixedCurrency:       http.StatusUnprocessableEntity,
+	CodeInvalidQuantity:     http.StatusBadRequest,
+	CodeInsufficientStock:   http.StatusConflict,
+	CodeInvalidStock:        http.StatusBadRequest,
+	CodeOrderNotFound:       http.StatusNotFound,
+	CodeOrderUserMismatch:   http.StatusConflict,
+	CodeInvalidOrderStatus:  http.StatusBadRequest,
+	CodeInvalidTransition:   http.StatusConflict,
+	CodeIdempotencyMismatch: http.StatusUnprocessableEntity,
+	CodeIdempotencyInFlight: http.StatusConflict,
+	CodeDeadLetterNotFound:  http.StatusNotFound,
+	CodeUnavailable:         http.StatusServiceUnavailable,
+	CodeInternal:            http.StatusInternalServerError,
+}
+
+// Status devuelve el estado HTTP que corresponde al código.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Details son datos adicionales del error que el cliente puede usar, por
+// ejemplo el stock disponible cuando no alcanza.
+type Details map[string]interface{}
+
+// Error es un error de la aplicación con un código y un mensaje que se puede
+// mostrar al cliente. El error original, si lo hay, solo se registra en el log.
+type Error struct {
+	Code    Code    `json:"code"`
+	Message string  `json:"message"`
+	Details Details `json:"details,omitempty"`
+	err     error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.err }
+
+// New crea un error con código y mensaje. Sirve para declarar errores
+// centinela que se comparan con errors.Is.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap crea un error con código y mensaje que conserva err como causa.
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, err: err}
+}
+
+// With devuelve un error con el mismo código, otro mensaje y detalles, que
+// sigue siendo e para errors.Is.
+func (e *Error) With(message string, details Details) *Error {
+	return &Error{Code: e.Code, Message: message, Details: details, err: e}
+}
+
+// From convierte cualquier error en un *Error. Si err envuelve un *Error se
+// conserva su código y el mensaje completo de err, que incluye el detalle
+// agregado con fmt.Errorf. Un registro no encontrado de GORM es NOT_FOUND y
+// cualquier otro error es INTERNAL_ERROR, sin exponer su texto.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		if appErr == err {
+			return appErr
+		}
+		return &Error{Code: appErr.Code, Message: err.Error(), Details: appErr.Details, err: err}
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Wrap(CodeNotFound, "resource not found", err)
+	}
+
+	log.Printf(" [!] Internal error: %s", err)
+	return Wrap(CodeInternal, "internal error", err)
+}
+
+// Body es el cuerpo JSON de las respuestas de error de la API REST.
+type Body struct {
+	Error *Error `json:"error"`
+}
+
+// Write responde err como JSON con el estado HTTP de su código y lo cuenta en
+// las métricas de errores.
+func Write(w http.ResponseWriter, err error) {
+	appErr := From(err)
+	metrics.CountError(metrics.TransportHTTP, string(appErr.Code))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(appErr.Code.Status())
+	json.NewEncoder(w).Encode(Body{Error: appErr})
+}
+
+// Response arma la respuesta de error para RabbitMQ. message conserva el
+// mensaje que ya esperan los clientes del pattern; el código va en Code, el
+// detalle en Data y los datos adicionales en Details.
+func Response(err error, message string) models.Response {
+	appErr := From(err)
+	metrics.CountError(metrics.TransportRPC, string(appErr.Code))
+	response := models.Response{
+		Success: "error",
+		Message: message,
+		Code:    string(appErr.Code),
+		Data:    []byte(appErr.Message),
+	}
+	if len(appErr.Details) > 0 {
+		if details, err := json.Marshal(appErr.Details); err == nil {
+			response.Details = details
+		}
+	}
+	return response
+}
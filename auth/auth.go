@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrMissingToken = apperrors.New(apperrors.CodeUnauthorized, "missing bearer token")
+	ErrInvalidToken = apperrors.New(apperrors.CodeUnauthorized, "invalid token")
+	ErrNoSecret     = apperrors.New(apperrors.CodeInternal, "authentication is not configured")
+)
+
+// Roles que puede traer el token.
+const (
+	RoleCustomer     = "customer"
+	RoleCatalogAdmin = "catalog-admin"
+	RoleSupport      = "support"
+)
+
+// OrderManagerRoles son los roles que pueden cambiar el estado de cualquier
+// orden. Los clientes solo pueden cancelar las suyas.
+var OrderManagerRoles = []string{RoleSupport, RoleCatalogAdmin}
+
+// Claims son los datos del token que usa el servicio. Subject es el
+// username del usuario autenticado y Roles sus roles; un token sin roles
+// se trata como customer.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+// HasRole indica si el token tiene alguno de los roles dados.
+func (c *Claims) HasRole(roles ...string) bool {
+	if c == nil {
+		return false
+	}
+	for _, have := range c.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// ParseBearer valida un header "Authorization: Bearer <token>" firmado con
+// HS256 y JWT_SECRET y devuelve sus claims.
+func ParseBearer(header string) (*Claims, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrMissingToken
+	}
+	return ParseToken(strings.TrimSpace(token))
+}
+
+// ParseToken valida un token HS256 firmado con JWT_SECRET. El token debe
+// tener subject y, si trae exp o nbf, estar vigente.
+func ParseToken(token string) (*Claims, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// WithClaims devuelve una copia de ctx con los claims del usuario autenticado.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext devuelve los claims guardados por WithClaims, si los hay.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
+
+// HasRole indica si el usuario autenticado en ctx tiene alguno de los roles.
+func HasRole(ctx context.Context, roles ...string) bool {
+	claims, _ := FromContext(ctx)
+	return claims.HasRole(roles...)
+}
+
+// Subject devuelve el username autenticado, o "" si el contexto no tiene claims.
+func Subject(ctx context.Context) string {
+	if claims, ok := FromContext(ctx); ok {
+		return claims.Subject
+	}
+	return ""
+}
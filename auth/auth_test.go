@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+// sign firma claims con method y key.
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return token
+}
+
+func hs256(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	return sign(t, jwt.SigningMethodHS256, []byte(testSecret), claims)
+}
+
+func registered(subject string, expiresIn time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}
+}
+
+func TestParseBearer(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	valid := hs256(t, Claims{RegisteredClaims: registered("ana", time.Hour), Roles: []string{RoleSupport}})
+
+	tests := []struct {
+		name   string
+		header string
+		want   error
+	}{
+		{"valid", "Bearer " + valid, nil},
+		{"lowercase scheme", "bearer " + valid, nil},
+		{"missing header", "", ErrMissingToken},
+		{"missing token", "Bearer ", ErrMissingToken},
+		{"basic scheme", "Basic " + valid, ErrMissingToken},
+		{"garbage", "Bearer not-a-jwt", ErrInvalidToken},
+		{"alg none", "Bearer " + sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType,
+			registered("ana", time.Hour)), ErrInvalidToken},
+		{"alg RS256", "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, registered("ana", time.Hour)), ErrInvalidToken},
+		{"alg HS512", "Bearer " + sign(t, jwt.SigningMethodHS512, []byte(testSecret), registered("ana", time.Hour)), ErrInvalidToken},
+		{"wrong secret", "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("other"), registered("ana", time.Hour)), ErrInvalidToken},
+		{"expired", "Bearer " + hs256(t, registered("ana", -time.Minute)), ErrInvalidToken},
+		{"not yet valid", "Bearer " + hs256(t, jwt.RegisteredClaims{
+			Subject:   "ana",
+			NotBefore: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}), ErrInvalidToken},
+		{"missing subject", "Bearer " + hs256(t, registered("", time.Hour)), ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseBearer(tt.header)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("error = %v; want %v", err, tt.want)
+				}
+				if claims != nil {
+					t.Errorf("claims = %+v; want nil", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBearer: %v", err)
+			}
+			if claims.Subject != "ana" || !claims.HasRole(RoleSupport) {
+				t.Errorf("claims = %+v; want subject ana with role %s", claims, RoleSupport)
+			}
+		})
+	}
+}
+
+func TestParseTokenWithoutSecret(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	token := hs256(t, registered("ana", time.Hour))
+
+	t.Setenv("JWT_SECRET", "")
+	if _, err := ParseToken(token); !errors.Is(err, ErrNoSecret) {
+		t.Fatalf("error = %v; want %v", err, ErrNoSecret)
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	claims := &Claims{Roles: []string{RoleCustomer}}
+
+	if !claims.HasRole(RoleSupport, RoleCustomer) {
+		t.Error("HasRole(support, customer) = false; want true")
+	}
+	if claims.HasRole(OrderManagerRoles...) {
+		t.Error("customer HasRole(OrderManagerRoles) = true; want false")
+	}
+	if (*Claims)(nil).HasRole(RoleCustomer) {
+		t.Error("nil claims HasRole = true; want false")
+	}
+}
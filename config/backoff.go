@@ -0,0 +1,62 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Backoff calcula esperas exponenciales entre intentos de conexión: empieza en
+// Initial, se duplica en cada intento y nunca supera Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	next    time.Duration
+}
+
+// Next devuelve la espera antes del próximo intento.
+func (b *Backoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.Initial
+	}
+	d := b.next
+	b.next *= 2
+	if b.next > b.Max {
+		b.next = b.Max
+	}
+	return d
+}
+
+// Reset vuelve a la espera inicial, después de una conexión exitosa.
+func (b *Backoff) Reset() {
+	b.next = 0
+}
+
+// Sleep espera d o hasta que ctx se cancela. Devuelve false si ctx se canceló.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// EnvDuration lee una duración de la variable de entorno name. Si no está
+// definida, no se puede interpretar o no es positiva, devuelve fallback.
+func EnvDuration(name string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// IdempotencyWindow devuelve durante cuánto tiempo se recuerdan las claves y
+// respuestas de idempotencia, tanto de HTTP como de RabbitMQ
+// (IDEMPOTENCY_WINDOW).
+func IdempotencyWindow() time.Duration {
+	return EnvDuration("IDEMPOTENCY_WINDOW", 24*time.Hour)
+}
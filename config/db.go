@@ -3,22 +3,42 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/FelipeGeraldoblufus/Cart/metrics"
 	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/tracing"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// SetupDatabase se conecta a la base de datos (DB_URL) y aplica las
+// migraciones. Si la base todavía no acepta conexiones, por ejemplo mientras
+// arranca el contenedor, reintenta con espera creciente durante
+// DB_CONNECT_TIMEOUT.
 func SetupDatabase() {
 	var dbURL = os.Getenv("DB_URL")
 	if dbURL == "" {
 		panic("DB_URL environment variable missing")
 	}
 
+	deadline := time.Now().Add(EnvDuration("DB_CONNECT_TIMEOUT", time.Minute))
+	backoff := &Backoff{Initial: 500 * time.Millisecond, Max: 10 * time.Second}
+
 	var err error
-	DB, err = gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+	for {
+		// TranslateError convierte las violaciones de unicidad en
+		// gorm.ErrDuplicatedKey, como promete repository.ErrDuplicatedKey
+		DB, err = gorm.Open(postgres.Open(dbURL), &gorm.Config{TranslateError: true})
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		wait := backoff.Next()
+		fmt.Printf("Failed to connect to database, retrying in %s: %s\n", wait, err)
+		time.Sleep(wait)
+	}
 
 	if err != nil {
 		panic(err)
@@ -26,13 +46,40 @@ func SetupDatabase() {
 		fmt.Println("Connected to database")
 	}
 
+	// Mide la duración de cada consulta y la registra en la traza del request
+	if err := DB.Use(metrics.GormPlugin{}); err != nil {
+		panic(err)
+	}
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		panic(err)
+	}
+
 	autoMigrate(DB)
 
 }
 
+// CloseDatabase cierra el pool de conexiones de la base de datos.
+func CloseDatabase() {
+	if DB == nil {
+		return
+	}
+	if sqlDB, err := DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
 func autoMigrate(connection *gorm.DB) {
+	if err := runMigrations(connection); err != nil {
+		panic(err)
+	}
+
 	connection.Debug().AutoMigrate(&models.Product{})
 	connection.Debug().AutoMigrate(&models.CartItem{})
 	connection.Debug().AutoMigrate(&models.User{})
 	connection.Debug().AutoMigrate(&models.Order{})
+	connection.Debug().AutoMigrate(&models.OrderLine{})
+	connection.Debug().AutoMigrate(&models.Inventory{})
+	connection.Debug().AutoMigrate(&models.OutboxEvent{})
+	connection.Debug().AutoMigrate(&models.ProcessedMessage{})
+	connection.Debug().AutoMigrate(&models.IdempotencyKey{})
 }
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"gorm.io/gorm"
+)
+
+// migration es un cambio de datos que AutoMigrate no puede hacer por sí solo.
+// Cada una se aplica una sola vez y queda registrada en schema_migrations.
+type migration struct {
+	version string
+	apply   func(tx *gorm.DB) error
+}
+
+// migrations se aplican en orden, antes de AutoMigrate, para que los datos
+// existentes cumplan los índices nuevos.
+var migrations = []migration{
+	{version: "0001_consolidate_duplicate_cart_items", apply: consolidateCartItems},
+}
+
+// runMigrations aplica las migraciones pendientes, cada una en su propia
+// transacción junto con su registro.
+func runMigrations(connection *gorm.DB) error {
+	if err := connection.AutoMigrate(&models.SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err := connection.Transaction(func(tx *gorm.DB) error {
+			var applied models.SchemaMigration
+			err := tx.Where("version = ?", m.version).First(&applied).Error
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			if err := m.apply(tx); err != nil {
+				return err
+			}
+			fmt.Printf("Applied migration %s\n", m.version)
+			return tx.Create(&models.SchemaMigration{Version: m.version, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// PendingMigrations devuelve las versiones que todavía no se aplicaron.
+func PendingMigrations(connection *gorm.DB) ([]string, error) {
+	var applied []string
+	if err := connection.Model(&models.SchemaMigration{}).Pluck("version", &applied).Error; err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+
+	var pending []string
+	for _, m := range migrations {
+		if !done[m.version] {
+			pending = append(pending, m.version)
+		}
+	}
+	return pending, nil
+}
+
+// consolidateCartItems junta las líneas repetidas de un mismo usuario y
+// producto en la de menor ID, sumando sus cantidades, para poder crear el
+// índice único de cart_items.
+func consolidateCartItems(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&models.CartItem{}) {
+		return nil
+	}
+
+	// Las líneas anteriores a la variante la tienen vacía
+	group := "user_id, product_id"
+	match := "c.user_id = k.user_id AND c.product_id = k.product_id"
+	if tx.Migrator().HasColumn(&models.CartItem{}, "Variant") {
+		group += ", variant"
+		match += " AND c.variant = k.variant"
+	}
+
+	if err := tx.Exec(`UPDATE cart_items SET quantity = d.total
+		FROM (SELECT MIN(id) AS keep_id, SUM(quantity) AS total FROM cart_items
+			GROUP BY ` + group + ` HAVING COUNT(*) > 1) d
+		WHERE cart_items.id = d.keep_id`).Error; err != nil {
+		return err
+	}
+
+	result := tx.Exec(`DELETE FROM cart_items c USING cart_items k
+		WHERE ` + match + ` AND c.id > k.id`)
+	if result.Error != nil {
+		return result.Error
+	}
+	fmt.Printf("Consolidated %d duplicate cart items\n", result.RowsAffected)
+	return nil
+}
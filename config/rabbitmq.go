@@ -1,41 +1,110 @@
 package config
 
 import (
-	"log"
+	"errors"
 	"os"
+	"sync"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func failOnError(err error, msg string) {
-	if err != nil {
-		log.Panicf("%s: %s", msg, err)
-	}
-}
+// CartQueue es la cola de la que el servicio consume comandos RPC.
+const CartQueue = "cart"
+
+var errNotConnected = errors.New("RabbitMQ is not connected")
 
-var ch *amqp.Channel
-var conn *amqp.Connection
+// La conexión y el canal del consumidor se reemplazan cuando el broker corta
+// la conexión, por eso se acceden con el mutex.
+var (
+	mu   sync.Mutex
+	conn *amqp.Connection
+	ch   *amqp.Channel
+)
 
-func SetupRabbitMQ() {
+// ConnectRabbitMQ devuelve el canal del consumidor. Si la conexión o el canal
+// están cerrados, vuelve a conectarse al servidor (RABBITMQ_URL) y abre un
+// canal nuevo.
+func ConnectRabbitMQ() (*amqp.Channel, error) {
 	URL := os.Getenv("RABBITMQ_URL")
 	if URL == "" {
-		failOnError(nil, "Failed to get RabbitMQ URL")
+		return nil, errors.New("RABBITMQ_URL environment variable missing")
 	}
 
-	//fmt.Println(URL)
-	conn, err := amqp.Dial(URL) //Establecer una conexion con el servidor de rabbitmq
-	failOnError(err, "Failed to connect to RabbitMQ")
+	mu.Lock()
+	defer mu.Unlock()
 
-	ch, err = conn.Channel() //Sesion o instancia de comunicacion con el servidor de rabbitmq
-	failOnError(err, "Failed to open a channel")
+	if conn == nil || conn.IsClosed() {
+		c, err := amqp.Dial(URL) //Establecer una conexion con el servidor de rabbitmq
+		if err != nil {
+			return nil, err
+		}
+		conn = c
+	}
 
+	if ch == nil || ch.IsClosed() {
+		c, err := conn.Channel() //Sesion o instancia de comunicacion con el servidor de rabbitmq
+		if err != nil {
+			return nil, err
+		}
+		ch = c
+	}
+
+	return ch, nil
 }
 
+// GetChannel devuelve el canal del consumidor, o nil si no hay conexión.
 func GetChannel() *amqp.Channel {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if ch == nil || ch.IsClosed() {
+		return nil
+	}
 	return ch
 }
 
+// NewChannel abre un canal adicional sobre la conexión existente, para
+// publicadores que no deben compartir el canal del consumidor.
+func NewChannel() (*amqp.Channel, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, errNotConnected
+	}
+	return conn.Channel()
+}
+
+// EventsExchange devuelve el nombre del exchange topic donde se publican los
+// eventos de dominio.
+func EventsExchange() string {
+	if name := os.Getenv("EVENTS_EXCHANGE"); name != "" {
+		return name
+	}
+	return "cart.events"
+}
+
+// ReconnectBackoff devuelve la espera entre intentos de reconexión a
+// RabbitMQ: empieza en RABBITMQ_RECONNECT_DELAY y llega hasta
+// RABBITMQ_RECONNECT_MAX_DELAY.
+func ReconnectBackoff() *Backoff {
+	return &Backoff{
+		Initial: EnvDuration("RABBITMQ_RECONNECT_DELAY", time.Second),
+		Max:     EnvDuration("RABBITMQ_RECONNECT_MAX_DELAY", 30*time.Second),
+	}
+}
+
 func CloseRabbitMQ() {
-	ch.Close()
-	conn.Close()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if ch != nil {
+		ch.Close()
+		ch = nil
+	}
+	if conn != nil {
+		conn.Close()
+		conn = nil
+	}
 }
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+var (
+	errNotOwner      = apperrors.New(apperrors.CodeForbidden, "you can only act on your own user")
+	errForbiddenRole = apperrors.New(apperrors.CodeForbidden, "you are not allowed to perform this operation")
+)
+
+// authorizeUsername verifica que el usuario autenticado sea username o tenga
+// alguno de los roles dados. Si no, responde 403 y devuelve false.
+func authorizeUsername(w http.ResponseWriter, r *http.Request, username string, roles ...string) bool {
+	if subject := auth.Subject(r.Context()); subject != "" && subject == username {
+		return true
+	}
+	if len(roles) > 0 && auth.HasRole(r.Context(), roles...) {
+		return true
+	}
+	apperrors.Write(w, errNotOwner)
+	return false
+}
+
+// authorizeUserID es authorizeUsername para los requests que identifican al
+// usuario por su ID. Devuelve el usuario para no volver a buscarlo.
+func authorizeUserID(w http.ResponseWriter, r *http.Request, userID uint) (models.User, bool) {
+	user, err := svc.Users.ByID(r.Context(), userID)
+	if err != nil {
+		apperrors.Write(w, err)
+		return user, false
+	}
+	return user, authorizeUsername(w, r, user.Username)
+}
+
+// authorizeOrder verifica que la orden pertenezca al usuario autenticado o
+// que este tenga alguno de los roles dados.
+func authorizeOrder(w http.ResponseWriter, r *http.Request, orderID uint, roles ...string) bool {
+	owner, err := svc.Orders.Owner(r.Context(), orderID)
+	if err != nil {
+		apperrors.Write(w, err)
+		return false
+	}
+	return authorizeUsername(w, r, owner, roles...)
+}
+
+// authorizeRoles verifica que el usuario autenticado tenga alguno de los roles
+// dados. Si no, responde 403 y devuelve false.
+func authorizeRoles(w http.ResponseWriter, r *http.Request, roles ...string) bool {
+	if auth.HasRole(r.Context(), roles...) {
+		return true
+	}
+	apperrors.Write(w, errForbiddenRole)
+	return false
+}
+
+// authorizeCartItem verifica que la línea del carrito pertenezca al usuario
+// autenticado.
+func authorizeCartItem(w http.ResponseWriter, r *http.Request, cartItemID uint) bool {
+	owner, err := svc.Cart.ItemOwner(r.Context(), cartItemID)
+	if err != nil {
+		apperrors.Write(w, err)
+		return false
+	}
+	return authorizeUsername(w, r, owner)
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/gorilla/mux"
+)
+
+func GetCartSummaryRest(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if !authorizeUsername(w, r, params["username"]) {
+		return
+	}
+
+	summary, err := svc.Cart.Summary(r.Context(), params["username"])
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&summary)
+}
@@ -0,0 +1,10 @@
+package controllers
+
+import "github.com/FelipeGeraldoblufus/Cart/apperrors"
+
+var errInvalidCartItemID = apperrors.New(apperrors.CodeInvalidRequest, "invalid cart item ID")
+
+// invalidBody envuelve un error al decodificar el cuerpo del request.
+func invalidBody(err error) error {
+	return apperrors.Wrap(apperrors.CodeInvalidRequest, "invalid request body: "+err.Error(), err)
+}
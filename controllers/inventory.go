@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/gorilla/mux"
+)
+
+func GetInventoryRest(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	inventory, err := svc.Catalog.Inventory(r.Context(), params["name"])
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&inventory)
+}
+
+func SetInventoryRest(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	var requestData struct {
+		Available int `json:"available"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		apperrors.Write(w, invalidBody(err))
+		return
+	}
+
+	inventory, err := svc.Catalog.SetInventory(r.Context(), params["name"], requestData.Available)
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&inventory)
+}
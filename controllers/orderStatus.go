@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/gorilla/mux"
+)
+
+// parseOrderID obtiene el ID de la orden desde los parámetros de la ruta.
+func parseOrderID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, apperrors.Wrap(apperrors.CodeInvalidRequest, "invalid order ID", err)
+	}
+	return uint(id), nil
+}
+
+func UpdateOrderStatusREST(w http.ResponseWriter, r *http.Request) {
+	orderID, err := parseOrderID(r)
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+	// Solo soporte y administración cambian el estado; el dueño puede cancelar
+	if !authorizeRoles(w, r, auth.OrderManagerRoles...) {
+		return
+	}
+
+	var requestData struct {
+		Status models.OrderStatus `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		apperrors.Write(w, invalidBody(err))
+		return
+	}
+
+	order, err := svc.Orders.UpdateStatus(r.Context(), orderID, requestData.Status)
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&order)
+}
+
+func CancelOrderREST(w http.ResponseWriter, r *http.Request) {
+	orderID, err := parseOrderID(r)
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+	if !authorizeOrder(w, r, orderID, auth.OrderManagerRoles...) {
+		return
+	}
+
+	order, err := svc.Orders.Cancel(r.Context(), orderID)
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&order)
+}
@@ -0,0 +1,12 @@
+package controllers
+
+import "github.com/FelipeGeraldoblufus/Cart/services"
+
+// svc son los servicios del dominio que usan los handlers REST. main los
+// configura con SetServices al iniciar el servicio.
+var svc *services.Services
+
+// SetServices configura los servicios que usan los handlers REST.
+func SetServices(s *services.Services) {
+	svc = s
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errDeliveriesClosed = errors.New("delivery channel closed")
+
+// consumerRegistered indica si el consumidor está registrado en la cola.
+var consumerRegistered atomic.Bool
+
+// consumerTag identifica al consumidor de este proceso, para poder cancelarlo
+// al apagar el servicio.
+func consumerTag() string {
+	return fmt.Sprintf("%s-%d", db.CartQueue, os.Getpid())
+}
+
+// StartConsumer consume los comandos RPC de la cola hasta que ctx se cancela.
+// Si el broker corta la conexión, vuelve a conectarse, reabre el canal y
+// registra de nuevo el consumidor, esperando cada vez más entre intentos.
+// Al cancelarse ctx deja de recibir mensajes y termina de procesar los que ya
+// fueron entregados antes de volver.
+func StartConsumer(ctx context.Context) {
+	backoff := db.ReconnectBackoff()
+
+	for {
+		err := consume(ctx, backoff)
+		if ctx.Err() != nil {
+			log.Printf(" [*] Consumer stopped")
+			return
+		}
+
+		wait := backoff.Next()
+		log.Printf(" [!] RabbitMQ consumer stopped, reconnecting in %s: %s", wait, err)
+		if !db.Sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+// consume abre el canal, declara la topología y procesa las entregas hasta
+// que el canal se cierra o ctx se cancela.
+func consume(ctx context.Context, backoff *db.Backoff) error {
+	ch, err := db.ConnectRabbitMQ()
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	msgs, err := registerConsumer(ch)
+	if err != nil {
+		// El canal queda cerrado tras un error del broker; se reabre en el
+		// próximo intento
+		ch.Close()
+		return err
+	}
+
+	backoff.Reset()
+	consumerRegistered.Store(true)
+	defer consumerRegistered.Store(false)
+	log.Printf(" [*] Awaiting RPC requests")
+
+	// Al salir se esperan las entregas que ya tomaron los workers
+	pool := newWorkerPool(ch, consumerWorkers(), consumerPrefetch())
+	defer pool.stop()
+
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			// Deja de recibir entregas nuevas; las que ya llegaron se procesan
+			// hasta que el broker cierra el canal de entregas
+			consumerRegistered.Store(false)
+			if err := ch.Cancel(consumerTag(), false); err != nil {
+				return err
+			}
+			done = nil
+		case d, ok := <-msgs:
+			if !ok {
+				return errDeliveriesClosed
+			}
+			pool.submit(d)
+		}
+	}
+}
+
+// registerConsumer verifica la cola, establece la calidad de servicio,
+// declara las colas de reintento y registra el consumidor.
+func registerConsumer(ch *amqp.Channel) (<-chan amqp.Delivery, error) {
+	q, err := ch.QueueDeclarePassive(
+		db.CartQueue, // name
+		false,        // durable
+		false,        // delete when unused
+		false,        // exclusive
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare a queue: %w", err)
+	}
+
+	err = ch.Qos(
+		consumerPrefetch(), // prefetch count: cuántos mensajes puede recibir el consumidor antes de confirmar.
+		0,                  // prefetch size: no se usa.
+		false,              // global: la configuración aplica al canal.
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	// Los reintentos y dead-letters se republican en este canal; en modo
+	// confirmación la entrega original se confirma solo cuando el broker
+	// aceptó la copia
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to put the channel in confirm mode: %w", err)
+	}
+
+	// Declara las colas de reintento y de dead-letters antes de procesar mensajes
+	if err := DeclareRetryTopology(ch); err != nil {
+		return nil, fmt.Errorf("failed to declare retry and dead-letter queues: %w", err)
+	}
+
+	msgs, err := ch.Consume(
+		q.Name,        // queue
+		consumerTag(), // consumer
+		false,         // auto-ack
+		false,         // exclusive
+		false,         // no-local
+		false,         // no-wait
+		nil,           // args
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register a consumer: %w", err)
+	}
+	return msgs, nil
+}
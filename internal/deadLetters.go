@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/gorilla/mux"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// deadLetterScanLimit acota cuántos mensajes se leen de la cola de
+// dead-letters en una sola operación de administración.
+const deadLetterScanLimit = 1000
+
+// redactBody quita el token de headers.Authorization del cuerpo del mensaje,
+// para que el listado de dead-letters no exponga credenciales. Los cuerpos que
+// no son un objeto JSON se devuelven tal cual.
+func redactBody(body []byte) string {
+	var payload map[string]json.RawMessage
+	if json.Unmarshal(body, &payload) != nil {
+		return string(body)
+	}
+
+	var headers map[string]json.RawMessage
+	if json.Unmarshal(payload["headers"], &headers) != nil {
+		return string(body)
+	}
+	if _, ok := headers["Authorization"]; !ok {
+		return string(body)
+	}
+	delete(headers, "Authorization")
+
+	redacted, err := json.Marshal(headers)
+	if err != nil {
+		return string(body)
+	}
+	payload["headers"] = redacted
+
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+func toDeadLetter(d amqp.Delivery) models.DeadLetter {
+	letter := models.DeadLetter{
+		ID:            headerString(d.Headers, headerDeadLetterID),
+		Error:         headerString(d.Headers, headerError),
+		RetryCount:    headerInt(d.Headers, headerRetryCount),
+		CorrelationID: d.CorrelationId,
+		ReplyTo:       d.ReplyTo,
+		Body:          redactBody(d.Body),
+	}
+	if failedAt, ok := d.Headers[headerFailedAt].(time.Time); ok {
+		letter.FailedAt = failedAt
+	}
+	return letter
+}
+
+// listLimit lee el parámetro limit de la consulta.
+func listLimit(r *http.Request, fallback int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return fallback
+	}
+	if limit > deadLetterScanLimit {
+		return deadLetterScanLimit
+	}
+	return limit
+}
+
+// ListDeadLettersRest lista los mensajes de la cola de dead-letters sin
+// consumirlos: se leen sin confirmar y, al cerrar el canal, el broker los
+// devuelve a la cola.
+func ListDeadLettersRest(w http.ResponseWriter, r *http.Request) {
+	ch, err := db.NewChannel()
+	if err != nil {
+		apperrors.Write(w, apperrors.Wrap(apperrors.CodeUnavailable, "RabbitMQ is not available", err))
+		return
+	}
+	defer ch.Close()
+
+	limit := listLimit(r, 50)
+	letters := make([]models.DeadLetter, 0, limit)
+	for len(letters) < limit {
+		d, ok, err := ch.Get(deadLetterQueue(), false)
+		if err != nil {
+			apperrors.Write(w, err)
+			return
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, toDeadLetter(d))
+	}
+
+	json.NewEncoder(w).Encode(&letters)
+}
+
+// replayDeadLetters vuelve a publicar en la cola principal hasta max mensajes
+// de dead-letters que cumplan match, sin los headers de error ni el contador
+// de reintentos. Los mensajes que no cumplen vuelven a la cola al cerrar el
+// canal.
+func replayDeadLetters(match func(amqp.Delivery) bool, max int) (int, error) {
+	ch, err := db.NewChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	// El dead letter se confirma solo cuando el broker aceptó la copia
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for scanned := 0; scanned < deadLetterScanLimit && replayed < max; scanned++ {
+		d, ok, err := ch.Get(deadLetterQueue(), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+		if !match(d) {
+			continue
+		}
+
+		headers := copyHeaders(d.Headers)
+		for _, key := range []string{headerRetryCount, headerError, headerFailedAt, headerDeadLetterID, headerOriginalQueue} {
+			delete(headers, key)
+		}
+		if err := republish(ch, d, "", db.CartQueue, headers); err != nil {
+			return replayed, err
+		}
+		if err := d.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func ReplayDeadLetterRest(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	replayed, err := replayDeadLetters(func(d amqp.Delivery) bool {
+		return headerString(d.Headers, headerDeadLetterID) == id
+	}, 1)
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+	if replayed == 0 {
+		apperrors.Write(w, apperrors.New(apperrors.CodeDeadLetterNotFound, "dead letter not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ReplayAllDeadLettersRest(w http.ResponseWriter, r *http.Request) {
+	replayed, err := replayDeadLetters(func(amqp.Delivery) bool { return true }, listLimit(r, deadLetterScanLimit))
+	if err != nil {
+		apperrors.Write(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"replayed": replayed})
+}
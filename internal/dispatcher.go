@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/services"
+)
+
+// route es el handler registrado para un pattern junto con los mensajes de su
+// respuesta.
+type route struct {
+	okMessage   string
+	failMessage string
+	handle      func(ctx context.Context, data json.RawMessage, claims *auth.Claims) (interface{}, error)
+}
+
+var routes = make(map[string]route)
+
+// svc son los servicios del dominio que ejecutan los patterns. Son los mismos
+// que usan los handlers REST, por lo que ambos transportes se comportan igual.
+var svc *services.Services
+
+// SetServices configura los servicios que usan los patterns.
+func SetServices(s *services.Services) {
+	svc = s
+}
+
+// legacySuccessLabels conserva la etiqueta de éxito original de algunos
+// patterns, porque hay clientes que comparan contra ella.
+var legacySuccessLabels = map[string]string{
+	"GET_USERBYNAME": "succes",
+}
+
+// validator lo implementan los requests que validan sus campos antes de
+// llegar al controlador.
+type validator interface {
+	Validate() error
+}
+
+// decodeError indica que el campo data del mensaje no se pudo decodificar.
+type decodeError struct{ err error }
+
+func (e decodeError) Error() string { return e.err.Error() }
+
+// validationError indica que el request no pasó su Validate.
+type validationError struct{ message string }
+
+func (e validationError) Error() string { return e.message }
+
+// owned lo implementan los requests que actúan sobre un usuario. Owner
+// devuelve el username afectado para compararlo con el del token.
+type owned interface {
+	Owner(ctx context.Context) (string, error)
+}
+
+// privileged lo implementan los requests owned que algunos roles pueden
+// ejecutar sobre cualquier usuario. Exigen token: el usuario debe ser el dueño
+// o tener alguno de los roles.
+type privileged interface {
+	PrivilegedRoles() []string
+}
+
+// restricted lo implementan los requests que solo pueden ejecutar ciertos
+// roles, aunque el usuario sea el dueño. Exigen token.
+type restricted interface {
+	RequiredRoles() []string
+}
+
+var (
+	// errUnauthorized indica que el pattern o el request exige un token y no
+	// venía.
+	errUnauthorized = apperrors.New(apperrors.CodeUnauthorized, "this pattern requires a bearer token")
+	// errForbidden indica que el token no permite la operación.
+	errForbidden = apperrors.New(apperrors.CodeForbidden, "you are not allowed to perform this operation")
+)
+
+// patternRoles restringe patterns a ciertos roles. Estos patterns exigen
+// token incluso a los servicios internos.
+var patternRoles = map[string][]string{
+	"CREATE_PRODUCT": {auth.RoleCatalogAdmin},
+	"EDIT_PRODUCT":   {auth.RoleCatalogAdmin},
+	"DELETE_PRODUCT": {auth.RoleCatalogAdmin},
+	"SET_INVENTORY":  {auth.RoleCatalogAdmin},
+}
+
+// authorizePattern verifica que claims tenga alguno de los roles que exige
+// pattern.
+func authorizePattern(pattern string, claims *auth.Claims) error {
+	roles, ok := patternRoles[pattern]
+	if !ok {
+		return nil
+	}
+	if claims == nil {
+		return errUnauthorized
+	}
+	if !claims.HasRole(roles...) {
+		return errForbidden
+	}
+	return nil
+}
+
+// authorize verifica que claims corresponda al dueño de req, o que tenga los
+// roles que exige req si implementa restricted o privileged. Los requests
+// restricted y privileged exigen token; el resto de los mensajes sin token
+// vienen de servicios internos y no se restringen.
+func authorize(ctx context.Context, req interface{}, claims *auth.Claims) error {
+	if claims == nil {
+		_, isRestricted := req.(restricted)
+		_, isPrivileged := req.(privileged)
+		if isRestricted || isPrivileged {
+			return errUnauthorized
+		}
+		return nil
+	}
+	if r, ok := req.(restricted); ok {
+		if !claims.HasRole(r.RequiredRoles()...) {
+			return errForbidden
+		}
+		return nil
+	}
+	o, ok := req.(owned)
+	if !ok {
+		return nil
+	}
+	if p, ok := req.(privileged); ok && claims.HasRole(p.PrivilegedRoles()...) {
+		return nil
+	}
+	owner, err := o.Owner(ctx)
+	if err != nil {
+		return err
+	}
+	if owner != claims.Subject {
+		return errForbidden
+	}
+	return nil
+}
+
+// register asocia un pattern con un handler tipado. El dispatcher decodifica
+// data en Req, lo valida si implementa validator, verifica el dueño si
+// implementa owned y serializa Resp como Data de la respuesta: nil se envía
+// vacío, []byte tal cual y el resto como JSON.
+func register[Req any, Resp any](pattern, okMessage, failMessage string, fn func(context.Context, Req) (Resp, error)) {
+	if _, exists := routes[pattern]; exists {
+		panic("internal: pattern registered twice: " + pattern)
+	}
+
+	routes[pattern] = route{
+		okMessage:   okMessage,
+		failMessage: failMessage,
+		handle: func(ctx context.Context, data json.RawMessage, claims *auth.Claims) (interface{}, error) {
+			var req Req
+			if len(data) > 0 {
+				if err := json.Unmarshal(data, &req); err != nil {
+					return nil, decodeError{err}
+				}
+			}
+			if v, ok := any(&req).(validator); ok {
+				if err := v.Validate(); err != nil {
+					return nil, validationError{err.Error()}
+				}
+			}
+			if err := authorize(ctx, req, claims); err != nil {
+				return nil, err
+			}
+			return fn(ctx, req)
+		},
+	}
+}
+
+// patternLabel devuelve el pattern para usarlo como etiqueta de métricas. Los
+// patterns desconocidos se agrupan, para que un cliente no pueda crear series
+// sin límite.
+func patternLabel(pattern string) string {
+	if _, ok := routes[pattern]; !ok {
+		return "unknown"
+	}
+	return pattern
+}
+
+// dispatch ejecuta el handler registrado para pattern y arma la respuesta.
+// claims es nil si el mensaje no traía token. También devuelve el error del
+// handler, para que el llamador pueda decidir si vale la pena reintentar.
+func dispatch(ctx context.Context, pattern string, data json.RawMessage, claims *auth.Claims) (models.Response, error) {
+	r, ok := routes[pattern]
+	if !ok {
+		return models.Response{
+			Success: "error",
+			Message: "unknown pattern",
+			Code:    string(apperrors.CodeUnknownPattern),
+			Details: json.RawMessage(`{"pattern":` + strconv.Quote(pattern) + `}`),
+			Data:    []byte(pattern),
+		}, nil
+	}
+
+	if err := authorizePattern(pattern, claims); err != nil {
+		return errorResponse(r, err), err
+	}
+
+	result, err := r.handle(ctx, data, claims)
+	if err != nil {
+		return errorResponse(r, err), err
+	}
+
+	body, err := encodeData(result)
+	if err != nil {
+		return apperrors.Response(err, "Error marshaling JSON"), nil
+	}
+
+	success := "success"
+	if label, ok := legacySuccessLabels[pattern]; ok {
+		success = label
+	}
+
+	return models.Response{
+		Success: success,
+		Message: r.okMessage,
+		Data:    body,
+	}, nil
+}
+
+func encodeData(result interface{}) ([]byte, error) {
+	switch v := result.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// errorResponse arma la respuesta de error con el código de apperrors. El
+// mensaje se mantiene igual que antes para los clientes que lo comparan.
+func errorResponse(r route, err error) models.Response {
+	var decodeErr decodeError
+	if errors.As(err, &decodeErr) {
+		return apperrors.Response(apperrors.Wrap(apperrors.CodeInvalidRequest, err.Error(), err), "Error decoding JSON")
+	}
+
+	var validationErr validationError
+	if errors.As(err, &validationErr) {
+		return apperrors.Response(apperrors.New(apperrors.CodeInvalidRequest, validationErr.message), validationErr.message)
+	}
+
+	return apperrors.Response(err, errorMessage(err, r.failMessage))
+}
+
+// errorMessage devuelve un mensaje específico para los errores que el cliente
+// debe distinguir, o fallback para el resto.
+func errorMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, errUnauthorized):
+		return "Unauthorized"
+	case errors.Is(err, errForbidden):
+		return "Forbidden"
+	case errors.Is(err, services.ErrInsufficientStock):
+		return "Insufficient stock"
+	case errors.Is(err, services.ErrInvalidTransition):
+		return "Invalid order status transition"
+	case errors.Is(err, services.ErrInvalidQuantity):
+		return "Invalid quantity"
+	}
+	return fallback
+}
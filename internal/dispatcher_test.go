@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+	"github.com/FelipeGeraldoblufus/Cart/services"
+)
+
+type ownedRequest struct{ owner string }
+
+func (r ownedRequest) Owner(ctx context.Context) (string, error) { return r.owner, nil }
+
+type privilegedRequest struct{ ownedRequest }
+
+func (privilegedRequest) PrivilegedRoles() []string { return []string{auth.RoleSupport} }
+
+type restrictedRequest struct{ ownedRequest }
+
+func (restrictedRequest) RequiredRoles() []string { return auth.OrderManagerRoles }
+
+func claimsFor(subject string, roles ...string) *auth.Claims {
+	claims := &auth.Claims{Roles: roles}
+	claims.Subject = subject
+	return claims
+}
+
+func TestAuthorize(t *testing.T) {
+	customer := claimsFor("ana", auth.RoleCustomer)
+	other := claimsFor("bea", auth.RoleCustomer)
+	support := claimsFor("sofia", auth.RoleSupport)
+	admin := claimsFor("carlos", auth.RoleCatalogAdmin)
+
+	tests := []struct {
+		name   string
+		req    interface{}
+		claims *auth.Claims
+		want   error
+	}{
+		{"not owned", struct{}{}, customer, nil},
+		{"owner", ownedRequest{"ana"}, customer, nil},
+		{"owner mismatch", ownedRequest{"ana"}, other, errForbidden},
+		{"owner mismatch with unrelated role", ownedRequest{"ana"}, support, errForbidden},
+		{"owned without token", ownedRequest{"ana"}, nil, nil},
+		{"privileged owner", privilegedRequest{ownedRequest{"ana"}}, customer, nil},
+		{"privileged role bypass", privilegedRequest{ownedRequest{"ana"}}, support, nil},
+		{"privileged other user", privilegedRequest{ownedRequest{"ana"}}, other, errForbidden},
+		{"privileged without token", privilegedRequest{ownedRequest{"ana"}}, nil, errUnauthorized},
+		{"restricted role", restrictedRequest{ownedRequest{"ana"}}, admin, nil},
+		{"restricted owner without role", restrictedRequest{ownedRequest{"ana"}}, customer, errForbidden},
+		{"restricted without token", restrictedRequest{ownedRequest{"ana"}}, nil, errUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorize(context.Background(), tt.req, tt.claims); !errors.Is(err, tt.want) {
+				t.Errorf("authorize = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		claims  *auth.Claims
+		want    error
+	}{
+		{"catalog admin", "CREATE_PRODUCT", claimsFor("carlos", auth.RoleCatalogAdmin), nil},
+		{"customer", "CREATE_PRODUCT", claimsFor("ana", auth.RoleCustomer), errForbidden},
+		{"support", "SET_INVENTORY", claimsFor("sofia", auth.RoleSupport), errForbidden},
+		{"without token", "DELETE_PRODUCT", nil, errUnauthorized},
+		{"unrestricted pattern", "GET_USERBYNAME", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorizePattern(tt.pattern, tt.claims); !errors.Is(err, tt.want) {
+				t.Errorf("authorizePattern = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDispatchAuthorization recorre los patterns registrados, para que un
+// pattern sensible no quede abierto a mensajes sin token.
+func TestDispatchAuthorization(t *testing.T) {
+	previous := svc
+	SetServices(services.New(repository.NewMemoryStore()))
+	t.Cleanup(func() { SetServices(previous) })
+
+	if _, err := svc.Users.Create(context.Background(), "ana"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		data     string
+		claims   *auth.Claims
+		wantCode apperrors.Code
+	}{
+		{"status change without token", "UPDATE_ORDER_STATUS", `{"orderID":1,"status":"paid"}`, nil, apperrors.CodeUnauthorized},
+		{"status change by owner", "UPDATE_ORDER_STATUS", `{"orderID":1,"status":"paid"}`, claimsFor("ana", auth.RoleCustomer), apperrors.CodeForbidden},
+		{"cancel without token", "CANCEL_ORDER", `{"orderID":1}`, nil, apperrors.CodeUnauthorized},
+		{"orders without token", "GET_ORDERSBYUSERNAME", `{"username":"ana"}`, nil, apperrors.CodeUnauthorized},
+		{"orders of another user", "GET_ORDERSBYUSERNAME", `{"username":"ana"}`, claimsFor("bea", auth.RoleCustomer), apperrors.CodeForbidden},
+		{"own orders", "GET_ORDERSBYUSERNAME", `{"username":"ana"}`, claimsFor("ana", auth.RoleCustomer), ""},
+		{"orders as support", "GET_ORDERSBYUSERNAME", `{"username":"ana"}`, claimsFor("sofia", auth.RoleSupport), ""},
+		{"product without token", "CREATE_PRODUCT", `{"name":"Polera"}`, nil, apperrors.CodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := dispatch(context.Background(), tt.pattern, json.RawMessage(tt.data), tt.claims)
+			if apperrors.Code(response.Code) != tt.wantCode {
+				t.Errorf("code = %q (%s); want %q", response.Code, response.Message, tt.wantCode)
+			}
+		})
+	}
+}
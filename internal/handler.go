@@ -3,665 +3,193 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"time"
 
-	//"github.com/ValeHenriquez/example-rabbit-go/tasks-server/controllers"
-	//"github.com/ValeHenriquez/example-rabbit-go/tasks-server/models"
-	"github.com/FelipeGeraldoblufus/Cart/controllers"
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/metrics"
 	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func failOnError(err error, msg string) {
-	if err != nil {
-		log.Panicf("%s: %s", msg, err)
-	}
-}
-
+// Handler procesa una entrega de la cola y responde en ReplyTo. Nunca hace
+// panic: un mensaje que no se puede procesar recibe una respuesta de error
+// (si tiene ReplyTo) y se envía a la cola de dead-letters. Las fallas
+// transitorias se reintentan con espera creciente.
 func Handler(d amqp.Delivery, ch *amqp.Channel) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// El comando continúa la traza del cliente que lo publicó; el nombre del
+	// span se completa con el pattern al decodificar el mensaje
+	ctx, span := tracing.Tracer().Start(tracing.ExtractAMQP(context.Background(), d.Headers), db.CartQueue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", db.CartQueue),
+			attribute.String("messaging.message.id", d.MessageId),
+			attribute.String("messaging.message.conversation_id", d.CorrelationId),
+		),
+	)
+	defer span.End()
+
+	replied := false
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf(" [!] Panic while handling message %s: %v\n%s", d.CorrelationId, r, debug.Stack())
+			span.SetStatus(codes.Error, fmt.Sprintf("panic: %v", r))
+			if !replied {
+				reply(ctx, ch, d, apperrors.Response(fmt.Errorf("panic: %v", r), "Internal error"))
+			}
+			fail(ch, d, fmt.Errorf("panic: %v", r))
+		}
+	}()
 
-	var response models.Response
 	log.Println(" [.] Received a message")
 
 	var Payload struct {
 		Pattern string          `json:"pattern"`
 		Data    json.RawMessage `json:"data"`
 		ID      string          `json:"id"`
+		Version int             `json:"version"`
+		Headers models.Headers  `json:"headers"`
+	}
+	if err := json.Unmarshal(d.Body, &Payload); err != nil {
+		log.Printf(" [!] Malformed message %s: %s", d.CorrelationId, err)
+		reply(ctx, ch, d, apperrors.Response(apperrors.Wrap(apperrors.CodeInvalidRequest, err.Error(), err), "Malformed message"))
+		replied = true
+		fail(ch, d, err)
+		return
 	}
-	var err error
-	err = json.Unmarshal(d.Body, &Payload)
-
-	actionType := Payload.Pattern
-
-	//dataJSON, err := json.Marshal(Payload.Data)
-	failOnError(err, "Failed to marshal data")
-	switch actionType {
-	case "GET_PRODUCT":
-		log.Println(" [.] Getting products")
-		/*products, err := controllers.GET()
-		log.Println(products)
-		failOnError(err, "Failed to get products")
-		productsJSON, err := json.Marshal(products)
-		failOnError(err, "Failed to marshal products")
-
-		response = models.Response{
-			Success: "succes",
-			Message: "Products retrieved",
-			Data:    productsJSON,
-		}*/
-
-	case "GET_USERBYNAME":
-		log.Println(" [.] Getting product by Name")
-		var data struct {
-			Name string `json:"username"`
-		}
-		var err error
-		var userJson []byte
-		var users models.User
-
-		err = json.Unmarshal(Payload.Data, &data)
-		users, err = controllers.GetByUser(data.Name)
-
-		userJson, err = json.Marshal(users)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error getting product",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "succes",
-				Message: "Product retrieved",
-				Data:    userJson,
-			}
-		}
-
-	case "EDIT_PRODUCT":
-		log.Println(" [.] Editing product by Name")
-		var data struct {
-			Product        string `json:"product"`
-			NewNameProduct string `json:"newnameProduct"`
-		}
-		var err error
-		var userJson []byte
-		var producto models.Product
-
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data)
-
-		// Decodificar los datos recibidos
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Llamada a la función para actualizar el producto
-		producto, err = controllers.UpdateProduct(data.Product, data.NewNameProduct)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error updating product",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Convertir el resultado a JSON y preparar la respuesta
-		userJson, err = json.Marshal(producto)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error marshaling JSON",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "success",
-				Message: "Product updated",
-				Data:    userJson,
-			}
-		}
-
-	case "CREATE_PRODUCT":
-		log.Println(" [.] Creating product")
-		var data struct {
-			Name string `json:"name"`
-		}
-		var err error
-		var dataJson []byte
-		var product models.Product
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.Name)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		product, err = controllers.CreateProduct(data.Name)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error creating product",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-		dataJson, err = json.Marshal(product)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error marshaling JSON",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "success",
-				Message: "Product created",
-				Data:    dataJson,
-			}
-		}
-
-	case "DELETE_PRODUCT":
-		log.Println(" [.] Deleting product")
-		var data struct {
-			Name string `json:"name"`
-		}
-		var err error
-		var dataJson []byte
-		var product models.Product
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.Name)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		err = controllers.DeleteProductByName(data.Name)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error Deleting product",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-		dataJson, err = json.Marshal(product)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error marshaling JSON",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "success",
-				Message: "Product deleted",
-				Data:    dataJson,
-			}
-		}
-
-	case "CREATE_CARTITEM":
-		log.Println(" [.] Creating cartitem")
-		var data struct {
-			Username    string `json:"username"`
-			ProductName string `json:"productName"`
-			Quantity    int    `json:"quantity"`
-		}
-		var err error
-		var cartitem *models.CartItem // Cambiado a puntero
-
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.Username, data.ProductName, data.Quantity)
-		var dataJson []byte
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Ahora asigna el resultado de la función a cartitem
-		cartitem, err = controllers.AddCartItemToUserByID(data.Username, data.ProductName, data.Quantity)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error creating cartitem",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Ahora puedes acceder a cartitem.ID
-		dataJson, err = json.Marshal(cartitem.ID)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error marshaling JSON",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "success",
-				Message: "Cartitem created",
-				Data:    dataJson,
-			}
-		}
-
-	case "EDIT_USER":
-		log.Println(" [.] Editing user")
-		var data struct {
-			CurrentUsername string `json:"currentUsername"`
-			NewUsername     string `json:"newUsername"`
-		}
-		var err error
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.CurrentUsername, data.NewUsername)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Llama a la función para editar el usuario
-		_, err = controllers.EditUser(data.CurrentUsername, data.NewUsername)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error editing user",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		response = models.Response{
-			Success: "success",
-			Message: "User edited successfully",
-			Data:    nil, // No necesitas enviar datos específicos en la respuesta
-		}
-
-	case "CREATE_USER":
-		log.Println(" [.] Creating user")
-		var data struct {
-			Username string `json:"username"`
-		}
-		var err error
-
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Verificar que el campo necesario (username) no esté vacío
-		if data.Username == "" {
-			response = models.Response{
-				Success: "error",
-				Message: "Username is required",
-				Data:    nil,
-			}
-			break
-		}
-
-		// Llama a la función para crear el usuario
-		createdUser, err := controllers.CreateUser(data.Username)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error creating user",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Convertir createdUser a formato JSON y luego a []byte
-		userData, err := json.Marshal(createdUser)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error encoding user data",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		response = models.Response{
-			Success: "success",
-			Message: "User created successfully",
-			Data:    userData,
-		}
-
-	case "DELETE_USER":
-		log.Println(" [.] Deleting user")
-		var data struct {
-			Username string `json:"username"`
-		}
-		var err error
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.Username)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Llama a la función para eliminar el CartItem
-		err = controllers.DeleteUser(data.Username)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error deleting cartitem",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		response = models.Response{
-			Success: "success",
-			Message: "User deleted successfully",
-			Data:    nil, // No necesitas enviar datos específicos en la respuesta
-		}
-
-	case "CREATE_ORDER":
-		log.Println(" [.] Creating order")
-		var data struct {
-			Username    string `json:"username"`
-			CartItemIDs []uint `json:"cartItemIDs"`
-		}
-		var err error
-
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Verificar que el campo necesario (username) no esté vacío
-		if data.Username == "" {
-			response = models.Response{
-				Success: "error",
-				Message: "Username is required",
-				Data:    nil,
-			}
-			break
-		}
-
-		// Llama a la función para crear la orden
-		createdOrder, err := controllers.CreateOrder(data.Username, data.CartItemIDs)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error creating order",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Convertir createdOrder a formato JSON y luego a []byte
-		orderData, err := json.Marshal(createdOrder)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error encoding order data",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		response = models.Response{
-			Success: "success",
-			Message: "Order created successfully",
-			Data:    orderData,
-		}
-	case "GET_ORDERSBYUSERNAME":
-		log.Println(" [.] Getting orders by Username")
-		var data struct {
-			Username string `json:"username"`
-		}
-		var err error
-		var ordersJson []byte
-		var orders []models.Order
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		orders, err = controllers.GetOrdersByUsername(data.Username)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error getting orders",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		ordersJson, err = json.Marshal(orders)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error marshaling JSON",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "success",
-				Message: "Orders retrieved",
-				Data:    ordersJson,
-			}
-		}
-
-	case "EDIT_CARTITEM":
-		log.Println(" [.] updating cartitem")
-		var data struct {
-			CartItemID uint `json:"cartItemID"`
-			Quantity   int  `json:"quantity"`
-		}
-		var err error
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.CartItemID, data.Quantity)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Llama a la función para eliminar el CartItem
-		err = controllers.UpdateCartItemQuantity(data.CartItemID, data.Quantity)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error updating cartitem",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		response = models.Response{
-			Success: "success",
-			Message: "CartItem updated successfully",
-			Data:    []byte("Cantidad actualizada exitosamente"), // No necesitas enviar datos específicos en la respuesta
-		}
-
-	case "EDIT_CARTITEMORDER":
-		log.Println(" [.] updating cartitem")
-		var data struct {
-			CartItemID uint `json:"cartItemID"`
-			Order      uint `json:"OrderID"`
-		}
-		var err error
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.CartItemID, data.Order)
-
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		// Llama a la función para eliminar el CartItem
-		err = controllers.UpdateCartItemOrder(data.CartItemID, data.Order)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error updating cartitem",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
-
-		response = models.Response{
-			Success: "success",
-			Message: "CartItem updated successfully",
-			Data:    []byte("Orden asignada exitosamente"), // No necesitas enviar datos específicos en la respuesta
-		}
 
-	case "DELETE_CARTITEM":
-		log.Println(" [.] Deleting cartitem")
-		var data struct {
-			Username   string `json:"username"`
-			CartItemID uint   `json:"cartItemID"`
-		}
-		var err error
-		// Log de depuración para verificar los datos recibidos
-		log.Printf("Received data: %+v\n", data.Username, data.CartItemID)
+	// Si el mensaje trae un token, el comando solo puede actuar sobre su usuario
+	claims, err := messageClaims(d, Payload.Headers)
+	if err != nil {
+		log.Printf(" [!] Unauthorized message %s: %s", d.CorrelationId, err)
+		if err := reply(ctx, ch, d, apperrors.Response(err, "Unauthorized")); err != nil {
+			reject(d, true)
+			return
+		}
+		replied = true
+		ack(d)
+		return
+	}
 
-		err = json.Unmarshal(Payload.Data, &data)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error decoding JSON",
-				Data:    []byte(err.Error()),
-			}
-			break
+	// Un comando ya procesado recibe la misma respuesta sin volver a ejecutarse
+	subject := ""
+	if claims != nil {
+		subject = claims.Subject
+	}
+	key := idempotencyKey(Payload.Pattern, subject, Payload.ID, d.MessageId)
+	if key != "" {
+		if cached, ok := lookupProcessed(key); ok {
+			log.Printf(" [.] Replaying response for %s %s", Payload.Pattern, key)
+			if err := reply(ctx, ch, d, cached); err != nil {
+				reject(d, true)
+				return
+			}
+			replied = true
+			ack(d)
+			return
 		}
+	}
 
-		// Llama a la función para eliminar el CartItem
-		_, err = controllers.RemoveCartItemFromUserByUsername(data.Username, data.CartItemID)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error deleting cartitem",
-				Data:    []byte(err.Error()),
-			}
-			break
-		}
+	log.Printf(" [.] Dispatching %s", Payload.Pattern)
+	span.SetName(patternLabel(Payload.Pattern))
+	span.SetAttributes(attribute.String("rpc.method", Payload.Pattern))
+	start := time.Now()
+	response, err := dispatch(ctx, Payload.Pattern, Payload.Data, claims)
+	metrics.ObserveRPC(patternLabel(Payload.Pattern), response.Success, time.Since(start))
+	if response.Success == "error" {
+		span.SetStatus(codes.Error, response.Code)
+	}
+	if err != nil && isTransient(err) {
+		replied = true
+		retry(ctx, ch, d, err, response)
+		return
+	}
 
-		response = models.Response{
-			Success: "success",
-			Message: "CartItem deleted successfully",
-			Data:    []byte("CartItem deleted successfully"), // No necesitas enviar datos específicos en la respuesta
-		}
-	case "CREATE_CATEGORY":
-		log.Println(" [.] Creating category")
-		//log.Println("data ", Payload.Data.Data)
-		//log.Println("data JSON", dataJSON)
+	if key != "" && cacheable(response) {
+		storeProcessed(key, Payload.Pattern, response)
+	}
 
-		/*var category models.Category
-		err := json.Unmarshal(Payload.Data.Data, &category)
-		failOnError(err, "Failed to unmarshal category")
+	if err := reply(ctx, ch, d, response); err != nil {
+		// La respuesta no salió; se reencola para reintentar más tarde
+		reject(d, true)
+		return
+	}
+	replied = true
 
-		log.Println("category ", category)
+	ack(d)
+}
 
-		categoryJson, err := json.Marshal(category)
-		failOnError(err, "Failed to marshal category")
+// messageClaims valida el token del mensaje, tomado del campo headers del
+// payload o, si no viene, del header AMQP Authorization. Devuelve nil sin
+// error cuando el mensaje no trae token.
+func messageClaims(d amqp.Delivery, headers models.Headers) (*auth.Claims, error) {
+	authorization := headers.Authorization
+	if authorization == "" {
+		authorization = headerString(d.Headers, "Authorization")
+	}
+	if authorization == "" {
+		return nil, nil
+	}
+	return auth.ParseBearer(authorization)
+}
 
-		//err = json.Unmarshal(categoryJson, &category)
+// headerResponseVersion indica la versión de respuesta pedida o enviada.
+const headerResponseVersion = "x-response-version"
 
-		_, err = controllers.CreateCategory(category)
-		if err != nil {
-			response = models.Response{
-				Success: "error",
-				Message: "Error creating category",
-				Data:    []byte(err.Error()),
-			}
-		} else {
-			response = models.Response{
-				Success: "succes",
-				Message: "Category created",
-				Data:    categoryJson,
-			}
-		}*/
+// responseVersion devuelve la versión de respuesta que pidió el cliente, con
+// el campo version del payload o el header AMQP x-response-version. Sin
+// ninguno se responde v1, como esperan los clientes existentes.
+func responseVersion(d amqp.Delivery) int {
+	var envelope struct {
+		Version int `json:"version"`
+	}
+	if json.Unmarshal(d.Body, &envelope) == nil && envelope.Version > 0 {
+		return envelope.Version
+	}
+	if version := headerInt(d.Headers, headerResponseVersion); version > 0 {
+		return version
+	}
+	return 1
+}
 
-		/*case "GET_TOP3POPULARPRODUCTS":
-		log.Println(" [.] Getting top 3 popular products")
+// encodeResponse serializa la respuesta en la versión pedida.
+func encodeResponse(version int, response models.Response) ([]byte, error) {
+	if version >= models.ResponseSchemaVersion {
+		return json.Marshal(response.V2())
+	}
+	return json.Marshal(response)
+}
 
-		products, err := controllers.GetTop3PopularProducts()
-		failOnError(err, "Failed to get products")
-		productsJSON, err := json.Marshal(products)
-		failOnError(err, "Failed to marshal products")
+// reply publica la respuesta en la cola ReplyTo de la entrega, en la versión
+// que pidió el cliente. Los mensajes sin ReplyTo no esperan respuesta y se
+// ignoran.
+func reply(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, response models.Response) error {
+	if d.ReplyTo == "" {
+		return nil
+	}
 
-		response = models.Response{
-			Success: "succes",
-			Message: "Products retrieved",
-			Data:    productsJSON,
-		}*/
+	version := responseVersion(d)
+	responseJSON, err := encodeResponse(version, response)
+	if err != nil {
+		log.Printf(" [!] Failed to marshal response: %s", err)
+		return err
 	}
 
-	responseJSON, err := json.Marshal(response)
-	failOnError(err, "Failed to marshal response")
+	// La respuesta lleva el contexto de traza para que el cliente la asocie
+	headers := amqp.Table{headerResponseVersion: int32(version)}
+	tracing.InjectAMQP(ctx, headers)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
 	err = ch.PublishWithContext(ctx,
 		"",        // exchange
@@ -671,9 +199,25 @@ func Handler(d amqp.Delivery, ch *amqp.Channel) {
 		amqp.Publishing{
 			ContentType:   "application/json",
 			CorrelationId: d.CorrelationId,
+			Headers:       headers,
 			Body:          responseJSON,
 		})
-	failOnError(err, "Failed to publish a message")
+	if err != nil {
+		log.Printf(" [!] Failed to publish reply for %s: %s", d.CorrelationId, err)
+	}
+	return err
+}
 
-	d.Ack(false)
+func ack(d amqp.Delivery) {
+	if err := d.Ack(false); err != nil {
+		log.Printf(" [!] Failed to ack message %s: %s", d.CorrelationId, err)
+	}
+}
+
+// reject hace Nack de la entrega. Sin requeue el broker la descarta o la
+// envía al dead-letter exchange de la cola.
+func reject(d amqp.Delivery, requeue bool) {
+	if err := d.Nack(false, requeue); err != nil {
+		log.Printf(" [!] Failed to nack message %s: %s", d.CorrelationId, err)
+	}
 }
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+// shuttingDown se activa al iniciar el apagado, para que el orquestador deje
+// de enviar tráfico antes de que el servicio se detenga.
+var shuttingDown atomic.Bool
+
+// MarkShuttingDown hace que /readyz empiece a fallar.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// readinessCheck revisa una dependencia del servicio.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var readinessChecks = []readinessCheck{
+	{name: "database", check: checkDatabase},
+	{name: "migrations", check: checkMigrations},
+	{name: "rabbitmq", check: checkRabbitMQ},
+	{name: "consumer", check: checkConsumer},
+}
+
+func checkDatabase(ctx context.Context) error {
+	if db.DB == nil {
+		return errors.New("database is not configured")
+	}
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func checkMigrations(ctx context.Context) error {
+	if db.DB == nil {
+		return errors.New("database is not configured")
+	}
+	pending, err := db.PendingMigrations(db.DB.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func checkRabbitMQ(ctx context.Context) error {
+	if db.GetChannel() == nil {
+		return errors.New("channel is not open")
+	}
+	return nil
+}
+
+func checkConsumer(ctx context.Context) error {
+	if !consumerRegistered.Load() {
+		return errors.New("consumer is not registered")
+	}
+	return nil
+}
+
+func writeHealth(w http.ResponseWriter, report models.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != models.HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(&report)
+}
+
+// HealthzRest indica que el proceso está vivo. No revisa dependencias, para
+// que una caída de la base o del broker no provoque reinicios.
+func HealthzRest(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, models.HealthReport{Status: models.HealthOK})
+}
+
+// ReadyzRest indica si el servicio puede recibir tráfico: la base responde,
+// las migraciones están aplicadas, el canal de RabbitMQ está abierto y el
+// consumidor registrado. Falla también mientras el servicio se apaga.
+func ReadyzRest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	report := models.HealthReport{
+		Status: models.HealthOK,
+		Checks: make(map[string]models.HealthCheck, len(readinessChecks)+1),
+	}
+	if shuttingDown.Load() {
+		report.Status = models.HealthFail
+		report.Checks["shutdown"] = models.HealthCheck{Status: models.HealthFail, Error: "service is shutting down"}
+	}
+
+	for _, c := range readinessChecks {
+		if err := c.check(ctx); err != nil {
+			report.Status = models.HealthFail
+			report.Checks[c.name] = models.HealthCheck{Status: models.HealthFail, Error: err.Error()}
+			continue
+		}
+		report.Checks[c.name] = models.HealthCheck{Status: models.HealthOK}
+	}
+
+	writeHealth(w, report)
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idempotencyKey identifica un comando: el id del sobre si viene, si no el
+// MessageId de AMQP, junto con el pattern y el usuario del token. Así otro
+// usuario que repita el mismo id no recibe una respuesta ajena. Sin id el
+// comando no es deduplicable.
+func idempotencyKey(pattern, subject, payloadID, messageID string) string {
+	id := payloadID
+	if id == "" {
+		id = messageID
+	}
+	if id == "" {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write([]byte(pattern))
+	h.Write([]byte{0})
+	h.Write([]byte(subject))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheable indica si la respuesta se guarda para repetirla. Las fallas de
+// autorización no se guardan, para que un reintento con el token correcto se
+// vuelva a evaluar.
+func cacheable(response models.Response) bool {
+	switch apperrors.Code(response.Code) {
+	case apperrors.CodeUnauthorized, apperrors.CodeForbidden:
+		return false
+	}
+	return true
+}
+
+// lookupProcessed busca la respuesta guardada para key dentro de la ventana.
+func lookupProcessed(key string) (models.Response, bool) {
+	var processed models.ProcessedMessage
+	err := db.DB.Where("key = ? AND created_at > ?", key, time.Now().Add(-db.IdempotencyWindow())).First(&processed).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf(" [!] Failed to look up processed message %s: %s", key, err)
+		}
+		return models.Response{}, false
+	}
+
+	var response models.Response
+	if err := json.Unmarshal(processed.Response, &response); err != nil {
+		log.Printf(" [!] Failed to decode processed message %s: %s", key, err)
+		return models.Response{}, false
+	}
+	return response, true
+}
+
+// storeProcessed guarda la respuesta enviada para key. Si había una entrada
+// vencida con la misma clave, se reemplaza.
+func storeProcessed(key, pattern string, response models.Response) {
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		log.Printf(" [!] Failed to encode processed message %s: %s", key, err)
+		return
+	}
+
+	err = db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"pattern", "response", "created_at"}),
+	}).Create(&models.ProcessedMessage{
+		Key:      key,
+		Pattern:  pattern,
+		Response: responseJSON,
+	}).Error
+	if err != nil {
+		log.Printf(" [!] Failed to store processed message %s: %s", key, err)
+	}
+}
+
+// StartIdempotencyJanitor elimina cada hora las respuestas de RabbitMQ y las
+// claves Idempotency-Key de HTTP fuera de la ventana de idempotencia, hasta
+// que ctx se cancela.
+func StartIdempotencyJanitor(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-db.IdempotencyWindow())
+			if err := db.DB.Where("created_at < ?", cutoff).Delete(&models.ProcessedMessage{}).Error; err != nil {
+				log.Printf(" [!] Failed to purge processed messages: %s", err)
+			}
+			if err := db.DB.Where("created_at < ?", cutoff).Delete(&models.IdempotencyKey{}).Error; err != nil {
+				log.Printf(" [!] Failed to purge idempotency keys: %s", err)
+			}
+		}
+	}
+}
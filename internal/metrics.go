@@ -0,0 +1,81 @@
+package internal
+
+import (
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// consumerCollector expone el estado del pool de workers y el retraso del
+// consumidor, medido como los mensajes que esperan en la cola del broker.
+type consumerCollector struct {
+	queued    *prometheus.Desc
+	inFlight  *prometheus.Desc
+	processed *prometheus.Desc
+	workers   *prometheus.Desc
+	prefetch  *prometheus.Desc
+	lag       *prometheus.Desc
+}
+
+func newConsumerCollector() *consumerCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "consumer", name), help, nil, nil)
+	}
+	return &consumerCollector{
+		queued:    desc("queued_deliveries", "Deliveries waiting for a worker."),
+		inFlight:  desc("in_flight_deliveries", "Deliveries being processed."),
+		processed: desc("processed_deliveries_total", "Deliveries processed since start."),
+		workers:   desc("workers", "Configured consumer workers."),
+		prefetch:  desc("prefetch", "Configured consumer prefetch count."),
+		lag:       desc("lag_messages", "Messages ready in the command queue and not yet delivered."),
+	}
+}
+
+func (c *consumerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queued
+	ch <- c.inFlight
+	ch <- c.processed
+	ch <- c.workers
+	ch <- c.prefetch
+	ch <- c.lag
+}
+
+func (c *consumerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := Stats()
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(stats.Queued))
+	ch <- prometheus.MustNewConstMetric(c.inFlight, prometheus.GaugeValue, float64(stats.InFlight))
+	ch <- prometheus.MustNewConstMetric(c.processed, prometheus.CounterValue, float64(stats.Processed))
+	ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(stats.Workers))
+	ch <- prometheus.MustNewConstMetric(c.prefetch, prometheus.GaugeValue, float64(stats.Prefetch))
+
+	// Sin conexión con el broker no se informa el retraso
+	if lag, ok := queueDepth(); ok {
+		ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(lag))
+	}
+}
+
+// queueDepth consulta cuántos mensajes esperan en la cola de comandos.
+func queueDepth() (int, bool) {
+	ch, err := db.NewChannel()
+	if err != nil {
+		return 0, false
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(
+		db.CartQueue, // name
+		false,        // durable
+		false,        // delete when unused
+		false,        // exclusive
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return 0, false
+	}
+	return q.Messages, true
+}
+
+func init() {
+	prometheus.MustRegister(newConsumerCollector())
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const outboxBatchSize = 100
+
+var errNacked = errors.New("publish was nacked by the broker")
+
+// outboxInterval devuelve cada cuánto se revisa el outbox (OUTBOX_POLL_INTERVAL).
+func outboxInterval() time.Duration {
+	return db.EnvDuration("OUTBOX_POLL_INTERVAL", time.Second)
+}
+
+// outboxRetention devuelve durante cuánto tiempo se conservan los eventos ya
+// publicados antes de borrarlos (OUTBOX_RETENTION).
+func outboxRetention() time.Duration {
+	return db.EnvDuration("OUTBOX_RETENTION", 7*24*time.Hour)
+}
+
+// StartOutboxRelay publica periódicamente los eventos pendientes del outbox
+// hasta que ctx se cancela. Usa un canal propio en modo confirmación: un
+// evento solo se marca como publicado cuando el broker lo confirma. Si la
+// conexión se corta, el canal se vuelve a abrir en la siguiente vuelta. Cada
+// hora borra los eventos publicados fuera de la retención.
+func StartOutboxRelay(ctx context.Context) {
+	var ch *amqp.Channel
+	defer func() {
+		if ch != nil {
+			ch.Close()
+		}
+	}()
+
+	exchange := db.EventsExchange()
+	ticker := time.NewTicker(outboxInterval())
+	defer ticker.Stop()
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-purge.C:
+			cutoff := time.Now().Add(-outboxRetention())
+			if err := db.DB.Where("published_at < ?", cutoff).Delete(&models.OutboxEvent{}).Error; err != nil {
+				log.Printf("Failed to purge published outbox events: %s", err)
+			}
+		case <-ticker.C:
+			if ch == nil || ch.IsClosed() {
+				var err error
+				if ch, err = openOutboxChannel(exchange); err != nil {
+					log.Printf("Outbox relay error: %s", err)
+					continue
+				}
+			}
+			// El lote en curso termina aunque se esté apagando el servicio;
+			// main espera al relay antes de cerrar la base
+			if err := relayOutbox(context.WithoutCancel(ctx), ch, exchange); err != nil {
+				log.Printf("Outbox relay error: %s", err)
+			}
+		}
+	}
+}
+
+// openOutboxChannel abre el canal del outbox, declara el exchange de eventos y
+// pone el canal en modo confirmación.
+func openOutboxChannel(exchange string) (*amqp.Channel, error) {
+	ch, err := db.NewChannel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox channel: %w", err)
+	}
+
+	err = ch.ExchangeDeclare(
+		exchange, // name
+		"topic",  // type
+		true,     // durable
+		false,    // auto-deleted
+		false,    // internal
+		false,    // no-wait
+		nil,      // arguments
+	)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to declare events exchange: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to put outbox channel in confirm mode: %w", err)
+	}
+	return ch, nil
+}
+
+// relayOutbox publica un lote de eventos pendientes en orden de inserción.
+// Las filas se bloquean con SKIP LOCKED para que varias instancias del
+// servicio no publiquen el mismo evento.
+func relayOutbox(ctx context.Context, ch *amqp.Channel, exchange string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var events []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").
+			Order("id").
+			Limit(outboxBatchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := publishEvent(ctx, ch, exchange, event); err != nil {
+				// Se corta el lote para respetar el orden de los eventos
+				return tx.Model(&event).Updates(map[string]interface{}{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": err.Error(),
+				}).Error
+			}
+
+			now := time.Now()
+			if err := tx.Model(&event).Update("published_at", &now).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// publishEvent publica el evento y espera la confirmación del broker. El span
+// de publicación continúa la traza de la operación que generó el evento y su
+// contexto viaja en los headers del mensaje.
+func publishEvent(ctx context.Context, ch *amqp.Channel, exchange string, event models.OutboxEvent) (err error) {
+	var carrier map[string]string
+	if len(event.TraceContext) > 0 {
+		json.Unmarshal(event.TraceContext, &carrier)
+	}
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, carrier), exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", event.Type),
+			attribute.String("messaging.message.id", event.EventID),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	headers := amqp.Table{}
+	tracing.InjectAMQP(ctx, headers)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+		exchange,   // exchange
+		event.Type, // routing key
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Headers:      headers,
+			MessageId:    event.EventID,
+			Type:         event.Type,
+			Timestamp:    event.CreatedAt,
+			Body:         event.Envelope,
+		})
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errNacked
+	}
+	return nil
+}
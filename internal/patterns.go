@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+type usernameRequest struct {
+	Username string `json:"username"`
+}
+
+func (r usernameRequest) Owner(ctx context.Context) (string, error) { return r.Username, nil }
+
+type productNameRequest struct {
+	Name string `json:"name"`
+}
+
+type editProductRequest struct {
+	Product        string  `json:"product"`
+	NewNameProduct *string `json:"newnameProduct"`
+	models.ProductInput
+}
+
+type setInventoryRequest struct {
+	Name      string `json:"name"`
+	Available int    `json:"available"`
+}
+
+type createCartItemRequest struct {
+	Username    string `json:"username"`
+	ProductName string `json:"productName"`
+	Variant     string `json:"variant"`
+	Quantity    int    `json:"quantity"`
+}
+
+func (r createCartItemRequest) Owner(ctx context.Context) (string, error) { return r.Username, nil }
+
+type editUserRequest struct {
+	CurrentUsername string `json:"currentUsername"`
+	NewUsername     string `json:"newUsername"`
+}
+
+func (r editUserRequest) Owner(ctx context.Context) (string, error) { return r.CurrentUsername, nil }
+
+type createUserRequest struct {
+	Username string `json:"username"`
+}
+
+func (r createUserRequest) Owner(ctx context.Context) (string, error) { return r.Username, nil }
+
+func (r createUserRequest) Validate() error {
+	if r.Username == "" {
+		return errors.New("Username is required")
+	}
+	return nil
+}
+
+type createOrderRequest struct {
+	Username    string `json:"username"`
+	CartItemIDs []uint `json:"cartItemIDs"`
+}
+
+func (r createOrderRequest) Owner(ctx context.Context) (string, error) { return r.Username, nil }
+
+func (r createOrderRequest) Validate() error {
+	if r.Username == "" {
+		return errors.New("Username is required")
+	}
+	return nil
+}
+
+type ordersByUsernameRequest struct {
+	Username string `json:"username"`
+	Status   string `json:"status"`
+}
+
+func (r ordersByUsernameRequest) Owner(ctx context.Context) (string, error) { return r.Username, nil }
+
+// Soporte y administración pueden listar las órdenes de cualquier usuario.
+func (ordersByUsernameRequest) PrivilegedRoles() []string {
+	return []string{auth.RoleSupport, auth.RoleCatalogAdmin}
+}
+
+type orderStatusRequest struct {
+	OrderID uint               `json:"orderID"`
+	Status  models.OrderStatus `json:"status"`
+}
+
+func (r orderStatusRequest) Owner(ctx context.Context) (string, error) {
+	return svc.Orders.Owner(ctx, r.OrderID)
+}
+
+// Soporte y administración pueden cancelar cualquier orden.
+func (orderStatusRequest) PrivilegedRoles() []string { return auth.OrderManagerRoles }
+
+// updateOrderStatusRequest cambia el estado de una orden. Solo lo pueden hacer
+// soporte y administración, aunque el usuario sea el dueño.
+type updateOrderStatusRequest struct {
+	orderStatusRequest
+}
+
+func (updateOrderStatusRequest) RequiredRoles() []string { return auth.OrderManagerRoles }
+
+type editCartItemRequest struct {
+	CartItemID uint `json:"cartItemID"`
+	Quantity   int  `json:"quantity"`
+}
+
+func (r editCartItemRequest) Owner(ctx context.Context) (string, error) {
+	return svc.Cart.ItemOwner(ctx, r.CartItemID)
+}
+
+type editCartItemOrderRequest struct {
+	CartItemID uint `json:"cartItemID"`
+	Order      uint `json:"OrderID"`
+}
+
+func (r editCartItemOrderRequest) Owner(ctx context.Context) (string, error) {
+	return svc.Cart.ItemOwner(ctx, r.CartItemID)
+}
+
+type deleteCartItemRequest struct {
+	Username   string `json:"username"`
+	CartItemID uint   `json:"cartItemID"`
+}
+
+func (r deleteCartItemRequest) Owner(ctx context.Context) (string, error) { return r.Username, nil }
+
+func init() {
+	register("GET_USERBYNAME", "Product retrieved", "Error getting product",
+		func(ctx context.Context, req usernameRequest) (models.User, error) {
+			return svc.Users.ByUsername(ctx, req.Username)
+		})
+
+	register("CREATE_PRODUCT", "Product created", "Error creating product",
+		func(ctx context.Context, req models.ProductInput) (models.Product, error) {
+			return svc.Catalog.CreateProduct(ctx, req)
+		})
+
+	register("EDIT_PRODUCT", "Product updated", "Error updating product",
+		func(ctx context.Context, req editProductRequest) (models.Product, error) {
+			// newnameProduct se mantiene por compatibilidad con clientes anteriores
+			if req.NewNameProduct != nil {
+				req.ProductInput.Name = req.NewNameProduct
+			}
+			return svc.Catalog.UpdateProduct(ctx, req.Product, req.ProductInput)
+		})
+
+	register("DELETE_PRODUCT", "Product deleted", "Error Deleting product",
+		func(ctx context.Context, req productNameRequest) (models.Product, error) {
+			return models.Product{}, svc.Catalog.DeleteProduct(ctx, req.Name)
+		})
+
+	register("SET_INVENTORY", "Inventory updated", "Error setting inventory",
+		func(ctx context.Context, req setInventoryRequest) (models.Inventory, error) {
+			return svc.Catalog.SetInventory(ctx, req.Name, req.Available)
+		})
+
+	register("CREATE_USER", "User created successfully", "Error creating user",
+		func(ctx context.Context, req createUserRequest) (models.User, error) {
+			return svc.Users.Create(ctx, req.Username)
+		})
+
+	register("EDIT_USER", "User edited successfully", "Error editing user",
+		func(ctx context.Context, req editUserRequest) ([]byte, error) {
+			_, err := svc.Users.Rename(ctx, req.CurrentUsername, req.NewUsername)
+			return nil, err
+		})
+
+	register("DELETE_USER", "User deleted successfully", "Error deleting user",
+		func(ctx context.Context, req usernameRequest) ([]byte, error) {
+			return nil, svc.Users.Delete(ctx, req.Username)
+		})
+
+	register("CREATE_CARTITEM", "Cartitem created", "Error creating cartitem",
+		func(ctx context.Context, req createCartItemRequest) (uint, error) {
+			cartitem, err := svc.Cart.AddItem(ctx, req.Username, req.ProductName, req.Variant, req.Quantity)
+			if err != nil {
+				return 0, err
+			}
+			return cartitem.ID, nil
+		})
+
+	register("EDIT_CARTITEM", "CartItem updated successfully", "Error updating cartitem",
+		func(ctx context.Context, req editCartItemRequest) ([]byte, error) {
+			cartitem, err := svc.Cart.UpdateItem(ctx, req.CartItemID, req.Quantity, 0)
+			if err != nil {
+				return nil, err
+			}
+			// Una cantidad cero elimina la línea del carrito
+			if cartitem.Quantity == 0 {
+				return []byte("Producto eliminado del carrito"), nil
+			}
+			return []byte("Cantidad actualizada exitosamente"), nil
+		})
+
+	register("EDIT_CARTITEMORDER", "CartItem updated successfully", "Error updating cartitem",
+		func(ctx context.Context, req editCartItemOrderRequest) ([]byte, error) {
+			if err := svc.Orders.AddCartItem(ctx, req.CartItemID, req.Order); err != nil {
+				return nil, err
+			}
+			return []byte("Orden asignada exitosamente"), nil
+		})
+
+	register("DELETE_CARTITEM", "CartItem deleted successfully", "Error deleting cartitem",
+		func(ctx context.Context, req deleteCartItemRequest) ([]byte, error) {
+			if _, err := svc.Cart.RemoveItem(ctx, req.Username, req.CartItemID); err != nil {
+				return nil, err
+			}
+			return []byte("CartItem deleted successfully"), nil
+		})
+
+	register("GET_CART_SUMMARY", "Cart summary retrieved", "Error getting cart summary",
+		func(ctx context.Context, req usernameRequest) (models.CartSummary, error) {
+			return svc.Cart.Summary(ctx, req.Username)
+		})
+
+	register("CREATE_ORDER", "Order created successfully", "Error creating order",
+		func(ctx context.Context, req createOrderRequest) (models.Order, error) {
+			return svc.Orders.Create(ctx, req.Username, req.CartItemIDs)
+		})
+
+	register("GET_ORDERSBYUSERNAME", "Orders retrieved", "Error getting orders",
+		func(ctx context.Context, req ordersByUsernameRequest) ([]models.Order, error) {
+			return svc.Orders.ByUsername(ctx, req.Username, req.Status)
+		})
+
+	register("UPDATE_ORDER_STATUS", "Order status updated", "Error updating order status",
+		func(ctx context.Context, req updateOrderStatusRequest) (models.Order, error) {
+			return svc.Orders.UpdateStatus(ctx, req.OrderID, req.Status)
+		})
+
+	register("CANCEL_ORDER", "Order status updated", "Error updating order status",
+		func(ctx context.Context, req orderStatusRequest) (models.Order, error) {
+			return svc.Orders.Cancel(ctx, req.OrderID)
+		})
+}
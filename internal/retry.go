@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers que se agregan a los mensajes reintentados o enviados a dead-letter.
+const (
+	headerRetryCount    = "x-retry-count"
+	headerError         = "x-error"
+	headerFailedAt      = "x-failed-at"
+	headerDeadLetterID  = "x-dead-letter-id"
+	headerOriginalQueue = "x-original-queue"
+)
+
+func deadLetterExchange() string { return db.CartQueue + ".dlx" }
+
+func deadLetterQueue() string { return db.CartQueue + ".dead" }
+
+// maxRetries devuelve cuántas veces se reintenta un mensaje con una falla
+// transitoria antes de enviarlo a dead-letter (RABBITMQ_MAX_RETRIES).
+func maxRetries() int {
+	if n, err := strconv.Atoi(os.Getenv("RABBITMQ_MAX_RETRIES")); err == nil && n >= 0 {
+		return n
+	}
+	return 3
+}
+
+// retryDelay devuelve la espera antes del reintento número attempt. La espera
+// base es RABBITMQ_RETRY_DELAY y se duplica en cada reintento.
+func retryDelay(attempt int) time.Duration {
+	return db.EnvDuration("RABBITMQ_RETRY_DELAY", time.Second) << (attempt - 1)
+}
+
+// retryQueue es la cola de espera del reintento número attempt. El nombre
+// incluye la espera porque el TTL de una cola no se puede cambiar después de
+// declararla.
+func retryQueue(attempt int) string {
+	return fmt.Sprintf("%s.retry.%s", db.CartQueue, retryDelay(attempt))
+}
+
+// DeclareRetryTopology declara las colas de reintento y el exchange y la cola
+// de dead-letters. Cada cola de reintento retiene los mensajes durante su TTL
+// y luego los devuelve a la cola principal.
+func DeclareRetryTopology(ch *amqp.Channel) error {
+	for attempt := 1; attempt <= maxRetries(); attempt++ {
+		_, err := ch.QueueDeclare(
+			retryQueue(attempt), // name
+			true,                // durable
+			false,               // delete when unused
+			false,               // exclusive
+			false,               // no-wait
+			amqp.Table{
+				"x-message-ttl":             retryDelay(attempt).Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": db.CartQueue,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := ch.ExchangeDeclare(
+		deadLetterExchange(), // name
+		"direct",             // type
+		true,                 // durable
+		false,                // auto-deleted
+		false,                // internal
+		false,                // no-wait
+		nil,                  // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = ch.QueueDeclare(
+		deadLetterQueue(), // name
+		true,              // durable
+		false,             // delete when unused
+		false,             // exclusive
+		false,             // no-wait
+		nil,               // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	return ch.QueueBind(deadLetterQueue(), db.CartQueue, deadLetterExchange(), false, nil)
+}
+
+// isTransient indica si el error se debe a una falla de infraestructura, como
+// la base de datos caída, que puede resolverse reintentando más tarde.
+func isTransient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) ||
+		pgconn.SafeToRetry(err) ||
+		pgconn.Timeout(err)
+}
+
+// retry reprograma la entrega en la siguiente cola de reintento. Si ya agotó
+// los reintentos, responde el error al cliente y la envía a dead-letter.
+func retry(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, cause error, response models.Response) {
+	attempt := headerInt(d.Headers, headerRetryCount) + 1
+	if attempt > maxRetries() {
+		reply(ctx, ch, d, response)
+		fail(ch, d, fmt.Errorf("giving up after %d retries: %w", attempt-1, cause))
+		return
+	}
+
+	headers := copyHeaders(d.Headers)
+	headers[headerRetryCount] = int32(attempt)
+	headers[headerError] = cause.Error()
+
+	if err := republish(ch, d, "", retryQueue(attempt), headers); err != nil {
+		log.Printf(" [!] Failed to schedule retry for %s: %s", d.CorrelationId, err)
+		reject(d, true)
+		return
+	}
+
+	log.Printf(" [.] Retry %d/%d for %s in %s: %s", attempt, maxRetries(), d.CorrelationId, retryDelay(attempt), cause)
+	ack(d)
+}
+
+// fail envía la entrega a dead-letter con el error en los headers y la
+// confirma. Si no se puede publicar en dead-letter se reencola para no
+// perderla.
+func fail(ch *amqp.Channel, d amqp.Delivery, cause error) {
+	headers := copyHeaders(d.Headers)
+	headers[headerError] = cause.Error()
+	headers[headerFailedAt] = time.Now().UTC()
+	headers[headerDeadLetterID] = newDeadLetterID()
+	headers[headerOriginalQueue] = db.CartQueue
+
+	if err := republish(ch, d, deadLetterExchange(), db.CartQueue, headers); err != nil {
+		log.Printf(" [!] Failed to dead-letter %s: %s", d.CorrelationId, err)
+		reject(d, true)
+		return
+	}
+
+	log.Printf(" [!] Dead-lettered %s: %s", d.CorrelationId, cause)
+	ack(d)
+}
+
+// errNotConfirming indica que el canal no está en modo confirmación.
+var errNotConfirming = errors.New("channel is not in confirm mode")
+
+// republish publica una copia de la entrega con otros headers, conservando
+// las propiedades que el cliente necesita para recibir la respuesta. ch debe
+// estar en modo confirmación: republish vuelve recién cuando el broker
+// confirma la copia, para que el llamador pueda confirmar la original sin
+// riesgo de perder el mensaje.
+func republish(ch *amqp.Channel, d amqp.Delivery, exchange, key string, headers amqp.Table) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+		exchange, // exchange
+		key,      // routing key
+		false,    // mandatory
+		false,    // immediate
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			CorrelationId: d.CorrelationId,
+			ReplyTo:       d.ReplyTo,
+			MessageId:     d.MessageId,
+			Timestamp:     d.Timestamp,
+			Type:          d.Type,
+			Body:          d.Body,
+		})
+	if err != nil {
+		return err
+	}
+	if confirmation == nil {
+		return errNotConfirming
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errNacked
+	}
+	return nil
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	copied := make(amqp.Table, len(headers)+4)
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+}
+
+func headerInt(headers amqp.Table, key string) int {
+	switch v := headers[key].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+func headerString(headers amqp.Table, key string) string {
+	if v, ok := headers[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func newDeadLetterID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
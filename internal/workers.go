@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// consumerWorkers devuelve cuántos mensajes se procesan en paralelo
+// (RABBITMQ_WORKERS).
+func consumerWorkers() int {
+	if n, err := strconv.Atoi(os.Getenv("RABBITMQ_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// consumerPrefetch devuelve cuántos mensajes sin confirmar entrega el broker
+// al consumidor (RABBITMQ_PREFETCH). Por defecto, dos por worker.
+func consumerPrefetch() int {
+	if n, err := strconv.Atoi(os.Getenv("RABBITMQ_PREFETCH")); err == nil && n > 0 {
+		return n
+	}
+	return 2 * consumerWorkers()
+}
+
+// Contadores del consumidor, compartidos entre reconexiones.
+var (
+	queuedDeliveries   atomic.Int64
+	inFlightDeliveries atomic.Int64
+	processedTotal     atomic.Int64
+	currentPool        atomic.Pointer[workerPool]
+)
+
+// workerPool reparte las entregas entre workers. Los mensajes con la misma
+// clave de orden van siempre al mismo worker, así dos comandos de un usuario
+// nunca se procesan a la vez y se ejecutan en el orden en que llegaron. Los
+// mensajes sin clave se reparten por turnos.
+type workerPool struct {
+	ch       *amqp.Channel
+	prefetch int
+	queues   []chan amqp.Delivery
+	next     atomic.Uint32
+	wg       sync.WaitGroup
+}
+
+// newWorkerPool inicia los workers que procesan las entregas de ch.
+func newWorkerPool(ch *amqp.Channel, workers, prefetch int) *workerPool {
+	p := &workerPool{
+		ch:       ch,
+		prefetch: prefetch,
+		queues:   make([]chan amqp.Delivery, workers),
+	}
+	for i := range p.queues {
+		// El broker no entrega más de prefetch mensajes sin confirmar, por lo
+		// que submit nunca queda bloqueado por un worker ocupado
+		p.queues[i] = make(chan amqp.Delivery, prefetch)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	currentPool.Store(p)
+	return p
+}
+
+func (p *workerPool) work(queue <-chan amqp.Delivery) {
+	defer p.wg.Done()
+	for d := range queue {
+		queuedDeliveries.Add(-1)
+		inFlightDeliveries.Add(1)
+		Handler(d, p.ch) // Llama al manejador de mensajes internos con el mensaje y el canal de RabbitMQ
+		inFlightDeliveries.Add(-1)
+		processedTotal.Add(1)
+	}
+}
+
+// submit encola la entrega en el worker que le corresponde.
+func (p *workerPool) submit(d amqp.Delivery) {
+	queuedDeliveries.Add(1)
+	p.queues[p.shard(d)] <- d
+}
+
+// shard elige el worker de la entrega a partir de su clave de orden.
+func (p *workerPool) shard(d amqp.Delivery) int {
+	key := orderingKey(d)
+	if key == "" {
+		return int(p.next.Add(1) % uint32(len(p.queues)))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// stop espera a que los workers terminen las entregas encoladas.
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// orderingKey devuelve la clave con la que se reparte el mensaje entre los
+// workers: el username del dueño de los datos sobre los que actúa. Se toma del
+// payload si viene; si el mensaje actúa sobre una línea del carrito o una
+// orden, se busca su dueño con resolveOwner, y si no, se usa el usuario del
+// token. Así todos los comandos de un usuario caen en el mismo worker, aunque
+// lleguen por distintos patterns o los envíe otro usuario con privilegios. Los
+// mensajes malformados no tienen clave; el Handler los responde con error.
+func orderingKey(d amqp.Delivery) string {
+	var payload struct {
+		Data struct {
+			Username        string `json:"username"`
+			CurrentUsername string `json:"currentUsername"`
+			CartItemID      uint   `json:"cartItemID"`
+			OrderID         uint   `json:"orderID"`
+		} `json:"data"`
+		Headers models.Headers `json:"headers"`
+	}
+	if json.Unmarshal(d.Body, &payload) != nil {
+		return ""
+	}
+
+	switch {
+	case payload.Data.Username != "":
+		return "user:" + payload.Data.Username
+	case payload.Data.CurrentUsername != "":
+		return "user:" + payload.Data.CurrentUsername
+	}
+	if payload.Data.CartItemID != 0 {
+		key := fmt.Sprintf("cartitem:%d", payload.Data.CartItemID)
+		if owner := resolveOwner(key, func(ctx context.Context) (string, error) {
+			return svc.Cart.ItemOwner(ctx, payload.Data.CartItemID)
+		}); owner != "" {
+			return "user:" + owner
+		}
+	}
+	if payload.Data.OrderID != 0 {
+		key := fmt.Sprintf("order:%d", payload.Data.OrderID)
+		if owner := resolveOwner(key, func(ctx context.Context) (string, error) {
+			return svc.Orders.Owner(ctx, payload.Data.OrderID)
+		}); owner != "" {
+			return "user:" + owner
+		}
+	}
+	if claims, err := messageClaims(d, payload.Headers); err == nil && claims != nil && claims.Subject != "" {
+		return "user:" + claims.Subject
+	}
+	return ""
+}
+
+const (
+	// ownerCacheTTL es cuánto se recuerda el dueño de una línea u orden. Un
+	// usuario renombrado puede caer en otro worker hasta que vence.
+	ownerCacheTTL = time.Minute
+	// ownerCacheSize limita las entradas del caché; al llenarse se vacía.
+	ownerCacheSize = 10000
+	// ownerLookupTimeout acota la consulta para no frenar la recepción.
+	ownerLookupTimeout = 2 * time.Second
+)
+
+type ownerEntry struct {
+	username string
+	expires  time.Time
+}
+
+// ownerCache recuerda el dueño de las líneas y órdenes, para que repartir las
+// entregas casi nunca consulte la base.
+var ownerCache = struct {
+	sync.Mutex
+	entries map[string]ownerEntry
+}{entries: make(map[string]ownerEntry)}
+
+// resolveOwner devuelve el username dueño del recurso key, desde el caché o
+// con lookup. Devuelve "" si no se encuentra o la consulta falla.
+func resolveOwner(key string, lookup func(context.Context) (string, error)) string {
+	now := time.Now()
+
+	ownerCache.Lock()
+	entry, ok := ownerCache.entries[key]
+	ownerCache.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.username
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ownerLookupTimeout)
+	defer cancel()
+	username, err := lookup(ctx)
+	if err != nil || username == "" {
+		return ""
+	}
+
+	ownerCache.Lock()
+	if len(ownerCache.entries) >= ownerCacheSize {
+		ownerCache.entries = make(map[string]ownerEntry)
+	}
+	ownerCache.entries[key] = ownerEntry{username: username, expires: now.Add(ownerCacheTTL)}
+	ownerCache.Unlock()
+	return username
+}
+
+// Stats devuelve el estado del consumidor para medir la contrapresión: si
+// Queued crece o Saturated se mantiene en true, los workers no alcanzan a
+// procesar lo que entrega el broker.
+func Stats() models.ConsumerStats {
+	stats := models.ConsumerStats{
+		Workers:   consumerWorkers(),
+		Prefetch:  consumerPrefetch(),
+		Queued:    queuedDeliveries.Load(),
+		InFlight:  inFlightDeliveries.Load(),
+		Processed: processedTotal.Load(),
+	}
+
+	if p := currentPool.Load(); p != nil {
+		stats.Workers = len(p.queues)
+		stats.Prefetch = p.prefetch
+		stats.WorkerQueues = make([]int, len(p.queues))
+		for i, queue := range p.queues {
+			stats.WorkerQueues[i] = len(queue)
+		}
+	}
+	stats.Saturated = stats.Queued+stats.InFlight >= int64(stats.Prefetch)
+	return stats
+}
+
+// ConsumerStatsRest devuelve el estado del pool de workers del consumidor.
+func ConsumerStatsRest(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(Stats())
+}
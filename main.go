@@ -1,71 +1,43 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	//"github.com/ValeHenriquez/example-rabbit-go/users-server/config"
 	//"github.com/ValeHenriquez/example-rabbit-go/users-server/internal"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
 	"github.com/FelipeGeraldoblufus/Cart/config"
 	"github.com/FelipeGeraldoblufus/Cart/controllers"
 	"github.com/FelipeGeraldoblufus/Cart/internal"
+	"github.com/FelipeGeraldoblufus/Cart/middleware"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+	"github.com/FelipeGeraldoblufus/Cart/services"
+	"github.com/FelipeGeraldoblufus/Cart/tracing"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func failOnError(err error, msg string) {
-	if err != nil {
-		log.Panicf("%s: %s", msg, err)
-	}
-}
-
-func getChannel() *amqp.Channel {
-	ch := config.GetChannel()
-	if ch == nil {
-		log.Panic("Failed to get channel")
-	}
-	return ch
-}
-
-func declareQueue(ch *amqp.Channel) amqp.Queue {
-	q, err := ch.QueueDeclarePassive(
-		"cart", // name
-		false,  // durable
-		false,  // delete when unused
-		false,  // exclusive
-		false,  // no-wait
-		nil,    // arguments
-	)
-	failOnError(err, "Failed to declare a queue")
-	return q
+// shutdownTimeout devuelve cuánto se espera a que terminen las solicitudes y
+// los mensajes en curso al apagar el servicio (SHUTDOWN_TIMEOUT).
+func shutdownTimeout() time.Duration {
+	return config.EnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
 }
 
-// Establece la calidad de servicio (QoS) para el canal de RabbitMQ.
-func setQoS(ch *amqp.Channel) {
-	err := ch.Qos(
-		1,     // prefetch count: Especifica cuántos mensajes puede recibir un consumidor antes de que se detenga la entrega. En este caso, se establece en 1.
-		0,     // prefetch size: No se usa en este caso, se establece como 0.
-		false, // global: Indica si estas configuraciones de QoS se aplican a nivel de canal o a nivel de conexión. En este caso, es a nivel de canal (false).
-	)
-
-	failOnError(err, "Failed to set QoS")
-}
-
-// Registra un consumidor para la cola dada y devuelve un canal de entrega de mensajes.
-func registerConsumer(ch *amqp.Channel, q amqp.Queue) <-chan amqp.Delivery {
-	msgs, err := ch.Consume(
-		q.Name, // queue
-		"",     // consumer
-		false,  // auto-ack
-		false,  // exclusive
-		false,  // no-local
-		false,  // no-wait
-		nil,    // args
-	)
-	failOnError(err, "Failed to register a consumer")
-	return msgs
+// shutdownDelay devuelve cuánto se sigue atendiendo tráfico con /readyz
+// fallando antes de cerrar el servidor, para que el orquestador alcance a
+// sacar la instancia (SHUTDOWN_DELAY).
+func shutdownDelay() time.Duration {
+	return config.EnvDuration("SHUTDOWN_DELAY", 0)
 }
 
 func main() {
@@ -75,47 +47,139 @@ func main() {
 	godotenv.Load()
 	fmt.Println("Loaded env variables...")
 
+	// Configura el exporter de trazas (OTEL_TRACES_EXPORTER) y el propagador W3C
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Panicf("Failed to set up tracing: %s", err)
+	}
+
 	config.SetupDatabase()
+	svc := services.New(repository.NewGormStore(config.DB))
+	controllers.SetServices(svc)
+	internal.SetServices(svc)
 	fmt.Println("Database connection configured...")
 
-	config.SetupRabbitMQ()
-	fmt.Println("RabbitMQ Connection configured...")
+	// SIGINT o SIGTERM inician el apagado ordenado
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ch := getChannel()              // Obtiene un canal de RabbitMQ
-	q := declareQueue(ch)           // Declara una cola y obtiene su estructura
-	setQoS(ch)                      // Establece la calidad de servicio en el canal
-	msgs := registerConsumer(ch, q) // Registra un consumidor para la cola y obtiene un canal de entrega de mensajes
+	// El consumidor se conecta a RabbitMQ y se reconecta si el broker corta la
+	// conexión
+	consumerDone := make(chan struct{})
+	go func() {
+		internal.StartConsumer(ctx)
+		close(consumerDone)
+	}()
 
-	var forever chan struct{}
+	// Las tareas de fondo usan la base, por lo que se esperan antes de cerrarla
+	var background sync.WaitGroup
+	background.Add(2)
 	go func() {
-		for d := range msgs {
-			internal.Handler(d, ch) // Llama al manejador de mensajes internos con el mensaje y el canal de RabbitMQ
-		}
+		defer background.Done()
+		internal.StartOutboxRelay(ctx) // Publica los eventos de dominio pendientes
+	}()
+	go func() {
+		defer background.Done()
+		internal.StartIdempotencyJanitor(ctx) // Purga las respuestas RPC y las Idempotency-Key vencidas
+	}()
+	backgroundDone := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(backgroundDone)
 	}()
 
 	r := mux.NewRouter()
 
-	r.HandleFunc("/api/product", controllers.CreateProductRest).Methods("POST")
+	r.Use(middleware.Tracing, middleware.Metrics)
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", internal.HealthzRest).Methods("GET")
+	r.HandleFunc("/readyz", internal.ReadyzRest).Methods("GET")
+
+	// El catálogo se puede leer sin token, pero solo catalog-admin lo modifica
+	catalogAdmin := func(h http.HandlerFunc) http.Handler {
+		return middleware.RequireAuth(middleware.RequireRole(auth.RoleCatalogAdmin)(h))
+	}
+	r.Handle("/api/product", catalogAdmin(controllers.CreateProductRest)).Methods("POST")
 	r.HandleFunc("/api/product/{name}", controllers.GetProductRest).Methods("GET")
-	r.HandleFunc("/api/product/{name}", controllers.DeleteProductRest).Methods("DELETE")
-	r.HandleFunc("/api/product/{name}", controllers.UpdateProductRest).Methods("PUT")
+	r.Handle("/api/product/{name}", catalogAdmin(controllers.DeleteProductRest)).Methods("DELETE")
+	r.Handle("/api/product/{name}", catalogAdmin(controllers.UpdateProductRest)).Methods("PUT")
+	r.HandleFunc("/api/product/{name}/inventory", controllers.GetInventoryRest).Methods("GET")
+	r.Handle("/api/product/{name}/inventory", catalogAdmin(controllers.SetInventoryRest)).Methods("PUT")
+
+	// Las líneas del carrito solo las ve y modifica su dueño
+	cartItem := r.PathPrefix("/api/cartitem").Subrouter()
+	cartItem.Use(middleware.RequireAuth)
+	cartItem.Handle("", middleware.Idempotency(http.HandlerFunc(controllers.CreateCartItemRest))).Methods("POST")
+	cartItem.HandleFunc("/{id}", controllers.GetCartItemRest).Methods("GET")
+	cartItem.HandleFunc("/{id}", controllers.DeleteCartItemRest).Methods("DELETE")
+	cartItem.HandleFunc("/{id}", controllers.UpdateCartItemRest).Methods("PUT")
+
+	// Las rutas de usuario y de órdenes exigen un bearer token y solo actúan
+	// sobre el usuario autenticado.
+	user := r.PathPrefix("/api/user").Subrouter()
+	user.Use(middleware.RequireAuth)
+	user.HandleFunc("", controllers.CreateUserRest).Methods("POST")
+	user.HandleFunc("/{username}", controllers.GetUserRest).Methods("GET")
+	user.HandleFunc("/{username}/cart/summary", controllers.GetCartSummaryRest).Methods("GET")
+	user.Handle("/addcartitem", middleware.Idempotency(http.HandlerFunc(controllers.AddCartItemToUser))).Methods("POST")
+	user.HandleFunc("/removecartitem", controllers.RemoveCartItemFromUser).Methods("DELETE")
+	user.HandleFunc("/edituser", controllers.EditUserREST).Methods("PUT")
+	user.HandleFunc("/orders/{username}", controllers.GetOrdersByUsernameREST).Methods("GET")
+
+	order := r.PathPrefix("/api/order").Subrouter()
+	order.Use(middleware.RequireAuth)
+	order.Handle("", middleware.Idempotency(http.HandlerFunc(controllers.CreateOrderREST))).Methods("POST")
+	order.HandleFunc("/{id}/status", controllers.UpdateOrderStatusREST).Methods("PUT")
+	order.HandleFunc("/{id}/cancel", controllers.CancelOrderREST).Methods("POST")
+
+	// Las rutas de operación exponen mensajes de otros usuarios y pueden
+	// reinyectar comandos, por eso solo las usa soporte
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(middleware.RequireAuth, middleware.RequireRole(auth.RoleSupport))
+	admin.HandleFunc("/consumer", internal.ConsumerStatsRest).Methods("GET")
+	admin.HandleFunc("/deadletters", internal.ListDeadLettersRest).Methods("GET")
+	admin.HandleFunc("/deadletters/replay", internal.ReplayAllDeadLettersRest).Methods("POST")
+	admin.HandleFunc("/deadletters/{id}/replay", internal.ReplayDeadLetterRest).Methods("POST")
+
+	srv := &http.Server{Addr: ":3000", Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server error: %s", err)
+			stop()
+		}
+	}()
 
-	r.HandleFunc("/api/cartitem", controllers.CreateCartItemRest).Methods("POST")
-	r.HandleFunc("/api/cartitem/{id}", controllers.GetCartItemRest).Methods("GET")
-	r.HandleFunc("/api/cartitem/{id}", controllers.DeleteCartItemRest).Methods("DELETE")
-	r.HandleFunc("/api/cartitem/{id}", controllers.UpdateCartItemRest).Methods("PUT")
+	<-ctx.Done()
+	stop()
+	log.Printf(" [*] Shutting down...")
+	internal.MarkShuttingDown()
+	time.Sleep(shutdownDelay())
 
-	r.HandleFunc("/api/user", controllers.CreateUserRest).Methods("POST")
-	r.HandleFunc("/api/user/{username}", controllers.GetUserRest).Methods("GET")
-	r.HandleFunc("/api/user/addcartitem", controllers.AddCartItemToUser).Methods("POST")
-	r.HandleFunc("/api/user/removecartitem", controllers.RemoveCartItemFromUser).Methods("DELETE")
-	r.HandleFunc("/api/user/edituser", controllers.EditUserREST).Methods("PUT")
+	// Se dejan de aceptar solicitudes y mensajes nuevos y se esperan los que
+	// están en curso
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
 
-	r.HandleFunc("/api/order", controllers.CreateOrderREST).Methods("POST")
-	r.HandleFunc("/api/user/orders/{username}", controllers.GetOrdersByUsernameREST).Methods("GET")
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown error: %s", err)
+	}
 
-	http.ListenAndServe(":3000", r)
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		log.Printf(" [!] Timed out waiting for in-flight messages")
+	}
 
-	log.Printf(" [*] Awaiting RPC requests")
-	<-forever // Espera indefinidamente*/
+	select {
+	case <-backgroundDone:
+	case <-shutdownCtx.Done():
+		log.Printf(" [!] Timed out waiting for the outbox relay and janitor")
+	}
+
+	config.CloseRabbitMQ()
+	config.CloseDatabase()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Tracing shutdown error: %s", err)
+	}
+	log.Printf(" [*] Stopped")
 }
@@ -0,0 +1,84 @@
+// Package metrics define las métricas de Prometheus del servicio. Se exponen
+// en /metrics junto con las métricas del runtime de Go.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Namespace es el prefijo de todas las métricas del servicio.
+const Namespace = "cart"
+
+// Transportes por los que llega una solicitud, usados como etiqueta.
+const (
+	TransportHTTP = "http"
+	TransportRPC  = "rpc"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	rpcMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rpc_messages_total",
+		Help:      "RabbitMQ commands by pattern and result.",
+	}, []string{"pattern", "result"})
+
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "rpc_message_duration_seconds",
+		Help:      "RabbitMQ command latency by pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"pattern"})
+
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "errors_total",
+		Help:      "Error responses by transport and error code.",
+	}, []string{"transport", "code"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+// ObserveHTTP registra un request HTTP atendido.
+func ObserveHTTP(route, method string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(elapsed.Seconds())
+}
+
+// ObserveRPC registra un comando RabbitMQ procesado. result es el campo
+// success de la respuesta.
+func ObserveRPC(pattern, result string, elapsed time.Duration) {
+	rpcMessages.WithLabelValues(pattern, result).Inc()
+	rpcDuration.WithLabelValues(pattern).Observe(elapsed.Seconds())
+}
+
+// CountError registra una respuesta de error con su código.
+func CountError(transport, code string) {
+	errorsTotal.WithLabelValues(transport, code).Inc()
+}
+
+// ObserveQuery registra una consulta a la base de datos.
+func ObserveQuery(operation, table string, elapsed time.Duration) {
+	dbDuration.WithLabelValues(operation, table).Observe(elapsed.Seconds())
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+)
+
+// RequireAuth exige un bearer token HS256 válido y deja sus claims en el
+// contexto del request. Sin token o con un token inválido responde 401.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := auth.ParseBearer(r.Header.Get("Authorization"))
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoSecret) {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+			}
+			apperrors.Write(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+	})
+}
+
+// RequireRole deja pasar solo a los usuarios autenticados que tengan alguno
+// de los roles dados; al resto responde 403. Debe ir dentro de RequireAuth.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasRole(r.Context(), roles...) {
+				apperrors.Write(w, apperrors.New(apperrors.CodeForbidden, "this operation requires one of the roles: "+strings.Join(roles, ", ")))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func bearer(t *testing.T, subject string, roles ...string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: roles,
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return "Bearer " + token
+}
+
+// serve ejecuta handler con el header Authorization dado y devuelve el estado
+// y el subject que vio el handler final.
+func serve(t *testing.T, handler func(http.Handler) http.Handler, authorization string) (int, http.Header, string) {
+	t.Helper()
+	var subject string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = auth.Subject(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler(next).ServeHTTP(rec, req)
+	return rec.Code, rec.Header(), subject
+}
+
+func TestRequireAuth(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantSubject   string
+	}{
+		{"valid token", bearer(t, "ana"), http.StatusOK, "ana"},
+		{"missing token", "", http.StatusUnauthorized, ""},
+		{"invalid token", "Bearer not-a-jwt", http.StatusUnauthorized, ""},
+		{"missing subject", bearer(t, ""), http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header, subject := serve(t, RequireAuth, tt.authorization)
+			if status != tt.wantStatus || subject != tt.wantSubject {
+				t.Errorf("status %d subject %q; want %d %q", status, subject, tt.wantStatus, tt.wantSubject)
+			}
+			if status == http.StatusUnauthorized && header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q; want Bearer", header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	requireSupport := func(next http.Handler) http.Handler {
+		return RequireAuth(RequireRole(auth.RoleSupport)(next))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{"support", bearer(t, "ana", auth.RoleSupport), http.StatusOK},
+		{"one of several roles", bearer(t, "ana", auth.RoleCustomer, auth.RoleSupport), http.StatusOK},
+		{"customer", bearer(t, "ana", auth.RoleCustomer), http.StatusForbidden},
+		{"no roles", bearer(t, "ana"), http.StatusForbidden},
+		{"no token", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _, _ := serve(t, requireSupport, tt.authorization); status != tt.wantStatus {
+				t.Errorf("status = %d; want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
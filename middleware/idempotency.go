@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/auth"
+	db "github.com/FelipeGeraldoblufus/Cart/config"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"gorm.io/gorm/clause"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// requestScope identifica al usuario dueño del request, para que dos
+// usuarios puedan usar la misma clave sin interferir. Se usa el usuario
+// autenticado y, si no lo hay, el usuario indicado en el cuerpo.
+func requestScope(r *http.Request, body []byte) string {
+	if subject := auth.Subject(r.Context()); subject != "" {
+		return "subject:" + subject
+	}
+
+	var data struct {
+		Username string `json:"username"`
+		UserID   uint   `json:"userID"`
+		UserID2  uint   `json:"user_id"`
+	}
+	json.Unmarshal(body, &data)
+
+	switch {
+	case data.Username != "":
+		return "username:" + data.Username
+	case data.UserID != 0:
+		return fmt.Sprintf("user:%d", data.UserID)
+	case data.UserID2 != 0:
+		return fmt.Sprintf("user:%d", data.UserID2)
+	}
+	return "anonymous"
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder deja pasar la respuesta y guarda una copia de ella.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotency hace seguro reintentar un request con el header
+// Idempotency-Key: la primera respuesta se guarda por clave y usuario y se
+// repite para los reintentos. Reusar la clave con otro cuerpo responde 422 y
+// reintentar mientras el original sigue en curso responde 409. Las
+// respuestas 5xx no se guardan, para permitir reintentar.
+func Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apperrors.Write(w, apperrors.New(apperrors.CodeInvalidRequest, "Idempotency-Key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			apperrors.Write(w, apperrors.Wrap(apperrors.CodeInvalidRequest, "could not read request body", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := models.IdempotencyKey{
+			Key:         key,
+			Scope:       requestScope(r, body),
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			RequestHash: requestHash(r, body),
+		}
+
+		existing, err := claimIdempotencyKey(&record)
+		if err != nil {
+			apperrors.Write(w, err)
+			return
+		}
+		if existing != nil {
+			replayIdempotentResponse(w, record, *existing)
+			return
+		}
+
+		// Si el handler entra en pánico la clave se libera, para que el
+		// reintento no reciba 409 durante toda la ventana
+		defer func() {
+			if p := recover(); p != nil {
+				releaseIdempotencyKey(record)
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			releaseIdempotencyKey(record)
+			return
+		}
+
+		now := time.Now()
+		err = db.DB.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status_code":   recorder.status,
+			"content_type":  recorder.Header().Get("Content-Type"),
+			"response_body": recorder.body.Bytes(),
+			"completed_at":  &now,
+		}).Error
+		if err != nil {
+			log.Printf("Failed to store response for Idempotency-Key %s: %s", key, err)
+		}
+	})
+}
+
+// releaseIdempotencyKey borra la clave reclamada para que se pueda reintentar.
+func releaseIdempotencyKey(record models.IdempotencyKey) {
+	if err := db.DB.Delete(&models.IdempotencyKey{}, record.ID).Error; err != nil {
+		log.Printf("Failed to release Idempotency-Key %s: %s", record.Key, err)
+	}
+}
+
+// claimIdempotencyKey inserta la clave si no existe y devuelve nil. Si ya
+// existe, devuelve la fila guardada. Las claves fuera de la ventana de
+// idempotencia se descartan y se vuelven a reclamar.
+func claimIdempotencyKey(record *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing models.IdempotencyKey
+		if err := db.DB.Where("key = ? AND scope = ?", record.Key, record.Scope).First(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing.CreatedAt.After(time.Now().Add(-db.IdempotencyWindow())) {
+			return &existing, nil
+		}
+
+		if err := db.DB.Delete(&existing).Error; err != nil {
+			return nil, err
+		}
+		record.ID = 0
+	}
+
+	return nil, fmt.Errorf("could not claim Idempotency-Key %s", record.Key)
+}
+
+var (
+	errKeyMismatch   = apperrors.New(apperrors.CodeIdempotencyMismatch, "Idempotency-Key was already used with a different request")
+	errKeyInProgress = apperrors.New(apperrors.CodeIdempotencyInFlight, "a request with this Idempotency-Key is still in progress")
+)
+
+// replayIdempotentResponse responde un reintento con la respuesta guardada.
+func replayIdempotentResponse(w http.ResponseWriter, request, stored models.IdempotencyKey) {
+	if stored.RequestHash != request.RequestHash {
+		apperrors.Write(w, errKeyMismatch)
+		return
+	}
+	if stored.CompletedAt == nil {
+		apperrors.Write(w, errKeyInProgress)
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.ResponseBody)
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/metrics"
+	"github.com/gorilla/mux"
+)
+
+// statusRecorder guarda el estado HTTP que escribió el handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// routeTemplate devuelve la plantilla de la ruta de mux que atendió el request.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+// Metrics cuenta los requests y mide su latencia por plantilla de ruta de mux
+// (por ejemplo /api/product/{name}), para no crear una serie por cada valor de
+// los parámetros. Se registra con Router.Use.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		metrics.ObserveHTTP(route, r.Method, recorder.status, time.Since(start))
+	})
+}
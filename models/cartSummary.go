@@ -0,0 +1,22 @@
+package models
+
+// CartSummaryLine es el detalle de precio de una línea del carrito.
+type CartSummaryLine struct {
+	CartItemID  uint   `json:"cart_item_id"`
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Variant     string `json:"variant"`
+	UnitPrice   int64  `json:"unit_price"`
+	Quantity    int    `json:"quantity"`
+	Subtotal    int64  `json:"subtotal"`
+}
+
+// CartSummary resume el carrito de un usuario. Los montos están en unidades
+// menores de Currency, por lo que no hay redondeo.
+type CartSummary struct {
+	Username  string            `json:"username"`
+	Lines     []CartSummaryLine `json:"lines"`
+	ItemCount int               `json:"item_count"`
+	Total     int64             `json:"total"`
+	Currency  string            `json:"currency"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// ConsumerStats describe el estado del pool de workers que consume la cola de
+// comandos RPC.
+type ConsumerStats struct {
+	Workers      int   `json:"workers"`
+	Prefetch     int   `json:"prefetch"`
+	Queued       int64 `json:"queued"`        // entregas esperando un worker
+	InFlight     int64 `json:"in_flight"`     // entregas en proceso
+	Processed    int64 `json:"processed"`     // entregas procesadas desde el arranque
+	WorkerQueues []int `json:"worker_queues"` // entregas esperando en cada worker
+	Saturated    bool  `json:"saturated"`     // todo el prefetch está ocupado
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DeadLetter describe un mensaje que terminó en la cola de dead-letters.
+type DeadLetter struct {
+	ID            string    `json:"id"`
+	Error         string    `json:"error"`
+	FailedAt      time.Time `json:"failed_at"`
+	RetryCount    int       `json:"retry_count"`
+	CorrelationID string    `json:"correlation_id"`
+	ReplyTo       string    `json:"reply_to"`
+	Body          string    `json:"body"`
+}
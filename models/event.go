@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventSchemaVersion es la versión del sobre con que se publican los eventos.
+const EventSchemaVersion = 1
+
+// Tipos de eventos de dominio. También se usan como routing key en el exchange.
+const (
+	EventCartItemAdded      = "cart.item_added"
+	EventCartItemRemoved    = "cart.item_removed"
+	EventCartItemUpdated    = "cart.item_updated"
+	EventOrderCreated       = "order.created"
+	EventOrderLineAdded     = "order.line_added"
+	EventOrderStatusChanged = "order.status_changed"
+	EventProductUpdated     = "product.updated"
+	EventUserRenamed        = "user.renamed"
+)
+
+// EventEnvelope es el sobre versionado de todo evento publicado.
+type EventEnvelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	Source     string          `json:"source"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// OutboxEvent es un evento pendiente de publicar. Se inserta en la misma
+// transacción que el cambio que lo origina, de modo que un rollback descarta
+// también el evento.
+type OutboxEvent struct {
+	ID          uint       `gorm:"primaryKey"`
+	EventID     string     `gorm:"size:36;not null;uniqueIndex"`
+	Type        string     `gorm:"size:64;not null"`
+	Envelope    []byte     `gorm:"type:jsonb;not null"`
+	CreatedAt   time.Time  `gorm:"not null"`
+	PublishedAt *time.Time `gorm:"index"`
+	Attempts    int        `gorm:"not null;default:0"`
+	LastError   string     `gorm:"type:text;not null;default:''"`
+	// TraceContext guarda el contexto de traza W3C de la operación que generó
+	// el evento, para continuar la traza al publicarlo
+	TraceContext []byte `gorm:"type:jsonb"`
+}
+
+type CartItemEvent struct {
+	UserID     uint   `json:"user_id"`
+	CartItemID uint   `json:"cart_item_id"`
+	ProductID  uint   `json:"product_id"`
+	Variant    string `json:"variant,omitempty"`
+	Quantity   int    `json:"quantity"`
+	// PreviousQuantity es la cantidad anterior, solo en cart.item_updated
+	PreviousQuantity int `json:"previous_quantity,omitempty"`
+}
+
+type OrderStatusChangedEvent struct {
+	OrderID uint        `json:"order_id"`
+	UserID  uint        `json:"user_id"`
+	From    OrderStatus `json:"from"`
+	To      OrderStatus `json:"to"`
+}
+
+// OrderLineAddedEvent informa la línea agregada a una orden pendiente y el
+// nuevo total.
+type OrderLineAddedEvent struct {
+	OrderID uint      `json:"order_id"`
+	UserID  uint      `json:"user_id"`
+	Line    OrderLine `json:"line"`
+	Total   int64     `json:"total"`
+}
+
+type UserRenamedEvent struct {
+	UserID      uint   `json:"user_id"`
+	OldUsername string `json:"old_username"`
+	NewUsername string `json:"new_username"`
+}
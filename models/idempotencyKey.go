@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyKey guarda la primera respuesta de un request REST enviado con
+// el header Idempotency-Key. CompletedAt es nil mientras el request original
+// se está procesando.
+type IdempotencyKey struct {
+	ID           uint      `gorm:"primaryKey"`
+	Key          string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_key_scope"`
+	Scope        string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_key_scope"`
+	Method       string    `gorm:"size:16;not null"`
+	Path         string    `gorm:"size:255;not null"`
+	RequestHash  string    `gorm:"size:64;not null"`
+	StatusCode   int       `gorm:"not null;default:0"`
+	ContentType  string    `gorm:"size:255;not null;default:''"`
+	ResponseBody []byte    `gorm:"type:bytea"`
+	CreatedAt    time.Time `gorm:"not null;index"`
+	CompletedAt  *time.Time
+}
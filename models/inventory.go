@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Inventory lleva el stock de un producto. Available son las unidades que aún
+// se pueden vender y Reserved las comprometidas por órdenes no despachadas.
+// Un producto sin fila de inventario no controla stock.
+type Inventory struct {
+	ProductID uint      `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	Available int       `gorm:"not null;default:0" json:"available"`
+	Reserved  int       `gorm:"not null;default:0" json:"reserved"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ProcessedMessage guarda la respuesta enviada a un comando RPC, para
+// devolver la misma respuesta si el mensaje se vuelve a entregar.
+type ProcessedMessage struct {
+	Key       string    `gorm:"primaryKey;size:255"`
+	Pattern   string    `gorm:"size:64;not null"`
+	Response  []byte    `gorm:"type:bytea;not null"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
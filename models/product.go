@@ -1,23 +1,76 @@
 package models
 
+import "time"
+
+// DefaultCurrency es la moneda ISO 4217 asignada a productos sin moneda explícita.
+const DefaultCurrency = "CLP"
+
+// Product es un ítem del catálogo. Price se guarda en unidades menores de Currency.
 type Product struct {
-	ID   uint   `gorm:"primaryKey" json:"id"`
-	Name string `gorm:"not null;unique" json:"name"`
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"not null;unique" json:"name"`
+	SKU         string `gorm:"size:64;not null;default:'';uniqueIndex:idx_products_sku,where:sku <> ''" json:"sku"`
+	Description string `gorm:"type:text;not null;default:''" json:"description"`
+	Price       int64  `gorm:"not null;default:0" json:"price"`
+	Currency    string `gorm:"size:3;not null;default:'CLP'" json:"currency"`
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+	// MinQuantity y MaxQuantity limitan las unidades por línea del carrito
+	// (MaxQuantity 0 es sin límite) y QuantityStep obliga a comprar en
+	// múltiplos, por ejemplo packs de 6.
+	MinQuantity  int `gorm:"not null;default:1" json:"min_quantity"`
+	MaxQuantity  int `gorm:"not null;default:0" json:"max_quantity"`
+	QuantityStep int `gorm:"not null;default:1" json:"quantity_step"`
 }
 
+// ProductInput contiene los campos editables de un producto. Los campos nil no se modifican.
+type ProductInput struct {
+	Name        *string `json:"name"`
+	SKU         *string `json:"sku"`
+	Description *string `json:"description"`
+	Price       *int64  `json:"price"`
+	Currency    *string `json:"currency"`
+	Active      *bool   `json:"active"`
+
+	MinQuantity  *int `json:"min_quantity"`
+	MaxQuantity  *int `json:"max_quantity"`
+	QuantityStep *int `json:"quantity_step"`
+}
+
+// CartItem es una línea del carrito. Un usuario tiene a lo sumo una línea por
+// producto y variante: agregar el mismo producto suma a la cantidad existente.
 type CartItem struct {
 	ID        uint    `gorm:"primaryKey" json:"id"`
-	ProductID uint    `gorm:"not null" json:"product_id"`
+	ProductID uint    `gorm:"not null;uniqueIndex:idx_cart_items_user_product_variant,priority:2" json:"product_id"`
 	Product   Product `gorm:"foreignKey:ProductID" json:"product"`
+	Variant   string  `gorm:"size:64;not null;default:'';uniqueIndex:idx_cart_items_user_product_variant,priority:3" json:"variant"`
 	Quantity  int     `gorm:"not null" json:"quantity"`
-	UserID    uint    `gorm:"not null" json:"user_id"`
+	UserID    uint    `gorm:"not null;uniqueIndex:idx_cart_items_user_product_variant,priority:1" json:"user_id"`
 }
 
 type Order struct {
-	ID     uint       `gorm:"primaryKey" json:"id"`
-	UserID uint       `gorm:"not null" json:"user_id"`
-	User   User       `gorm:"foreignKey:ID" json:"user"`
-	Items  []CartItem `gorm:"foreignKey:ID" json:"items"`
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	UserID    uint        `gorm:"not null" json:"user_id"`
+	User      User        `gorm:"foreignKey:UserID" json:"user"`
+	Items     []OrderLine `gorm:"foreignKey:OrderID" json:"items"`
+	Total     int64       `gorm:"not null;default:0" json:"total"`
+	Currency  string      `gorm:"size:3;not null;default:'CLP'" json:"currency"`
+	Status    OrderStatus `gorm:"size:16;not null;default:'pending';index" json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderLine es la copia inmutable de una línea del carrito al momento del checkout.
+// Nombre y precio del producto se congelan para que cambios posteriores en el
+// catálogo no alteren órdenes pasadas.
+type OrderLine struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	OrderID     uint   `gorm:"not null;index" json:"order_id"`
+	ProductID   uint   `gorm:"not null" json:"product_id"`
+	ProductName string `gorm:"not null" json:"product_name"`
+	Variant     string `gorm:"size:64;not null;default:''" json:"variant"`
+	UnitPrice   int64  `gorm:"not null" json:"unit_price"`
+	Quantity    int    `gorm:"not null" json:"quantity"`
+	Subtotal    int64  `gorm:"not null" json:"subtotal"`
 }
 
 type User struct {
@@ -1,9 +1,73 @@
 package models
 
+import "encoding/json"
+
+// Response es la respuesta v1 del RPC. Data va como []byte, por lo que se
+// serializa en base64.
 type Response struct {
-	Success string `json:"success"`
-	Message string `json:"message"`
-	Data    []byte `json:"data"`
+	Success string          `json:"success"`
+	Message string          `json:"message"`
+	Code    string          `json:"code,omitempty"`
+	Details json.RawMessage `json:"details,omitempty"`
+	Data    []byte          `json:"data"`
+}
+
+// ResponseSchemaVersion es la versión de ResponseV2.
+const ResponseSchemaVersion = 2
+
+type ResponseStatus string
+
+const (
+	ResponseOK    ResponseStatus = "ok"
+	ResponseError ResponseStatus = "error"
+)
+
+// ErrorDetail describe el error de una ResponseV2.
+type ErrorDetail struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// ResponseV2 es la respuesta del RPC para los clientes que la piden. Data va
+// como JSON embebido y los errores traen código y detalles.
+type ResponseV2 struct {
+	Version int             `json:"version"`
+	Status  ResponseStatus  `json:"status"`
+	Message string          `json:"message,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   *ErrorDetail    `json:"error,omitempty"`
+}
+
+// V2 convierte la respuesta al formato v2.
+func (r Response) V2() ResponseV2 {
+	if r.Success == "error" {
+		return ResponseV2{
+			Version: ResponseSchemaVersion,
+			Status:  ResponseError,
+			Message: r.Message,
+			Error: &ErrorDetail{
+				Code:    r.Code,
+				Message: string(r.Data),
+				Details: r.Details,
+			},
+		}
+	}
+
+	response := ResponseV2{
+		Version: ResponseSchemaVersion,
+		Status:  ResponseOK,
+		Message: r.Message,
+	}
+	if len(r.Data) > 0 {
+		if json.Valid(r.Data) {
+			response.Data = json.RawMessage(r.Data)
+		} else {
+			// Data que no es JSON se envía como string
+			response.Data, _ = json.Marshal(string(r.Data))
+		}
+	}
+	return response
 }
 
 type Headers struct {
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SchemaMigration registra una migración de datos ya aplicada, para que cada
+// una corra una sola vez.
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:128"`
+	AppliedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormStore implementa Store sobre Postgres con GORM.
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore devuelve un Store que usa db.
+func NewGormStore(db *gorm.DB) Store {
+	return gormStore{db: db}
+}
+
+func (s gormStore) Products() ProductRepository    { return gormProducts{s.db} }
+func (s gormStore) Users() UserRepository          { return gormUsers{s.db} }
+func (s gormStore) CartItems() CartItemRepository  { return gormCartItems{s.db} }
+func (s gormStore) Orders() OrderRepository        { return gormOrders{s.db} }
+func (s gormStore) Inventory() InventoryRepository { return gormInventory{s.db} }
+func (s gormStore) Outbox() OutboxRepository       { return gormOutbox{s.db} }
+
+func (s gormStore) WithContext(ctx context.Context) Store {
+	return gormStore{db: s.db.WithContext(ctx)}
+}
+
+func (s gormStore) Context() context.Context {
+	if s.db.Statement.Context == nil {
+		return context.Background()
+	}
+	return s.db.Statement.Context
+}
+
+func (s gormStore) Transaction(fn func(tx Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(gormStore{db: tx})
+	})
+}
+
+// exists indica si la consulta encuentra al menos un registro.
+func exists(query *gorm.DB) (bool, error) {
+	var found struct{ ID uint }
+	err := query.Select("id").Take(&found).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+type gormProducts struct{ db *gorm.DB }
+
+func (r gormProducts) FindByID(id uint) (models.Product, error) {
+	var product models.Product
+	err := r.db.First(&product, id).Error
+	return product, err
+}
+
+func (r gormProducts) FindByName(name string) (models.Product, error) {
+	var product models.Product
+	err := r.db.Where("name = ?", name).First(&product).Error
+	return product, err
+}
+
+func (r gormProducts) NameTaken(name string, exceptID uint) (bool, error) {
+	return exists(r.db.Model(&models.Product{}).Where("name = ? AND id <> ?", name, exceptID))
+}
+
+func (r gormProducts) SKUTaken(sku string, exceptID uint) (bool, error) {
+	return exists(r.db.Model(&models.Product{}).Where("sku = ? AND id <> ?", sku, exceptID))
+}
+
+func (r gormProducts) Create(product *models.Product) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		// GORM omite los valores cero al insertar, por lo que un producto
+		// inactivo quedaría con el default de la columna
+		if !product.Active {
+			return tx.Model(product).Update("active", false).Error
+		}
+		return nil
+	})
+}
+
+func (r gormProducts) Save(product *models.Product) error {
+	return r.db.Save(product).Error
+}
+
+func (r gormProducts) Delete(product *models.Product) error {
+	return r.db.Delete(product).Error
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) List() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Preload("Cart.Product").Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) FindByID(id uint) (models.User, error) {
+	var user models.User
+	err := r.db.Preload("Cart.Product").First(&user, id).Error
+	return user, err
+}
+
+func (r gormUsers) FindByUsername(username string) (models.User, error) {
+	var user models.User
+	err := r.db.Preload("Cart.Product").Where("username = ?", username).First(&user).Error
+	return user, err
+}
+
+func (r gormUsers) Create(user *models.User) error {
+	return r.db.Omit(clause.Associations).Create(user).Error
+}
+
+func (r gormUsers) Save(user *models.User) error {
+	return r.db.Omit(clause.Associations).Save(user).Error
+}
+
+func (r gormUsers) Delete(user *models.User) error {
+	return r.db.Delete(user).Error
+}
+
+type gormCartItems struct{ db *gorm.DB }
+
+func (r gormCartItems) FindByID(id uint) (models.CartItem, error) {
+	var item models.CartItem
+	err := r.db.Preload("Product").First(&item, id).Error
+	return item, err
+}
+
+func (r gormCartItems) Create(item *models.CartItem) error {
+	return r.db.Omit(clause.Associations).Create(item).Error
+}
+
+func (r gormCartItems) Save(item *models.CartItem) error {
+	return r.db.Omit(clause.Associations).Save(item).Error
+}
+
+func (r gormCartItems) Delete(item *models.CartItem) error {
+	return r.db.Delete(item).Error
+}
+
+func (r gormCartItems) Merge(item *models.CartItem) error {
+	return r.db.Omit(clause.Associations).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "product_id"}, {Name: "variant"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"quantity": gorm.Expr("cart_items.quantity + excluded.quantity"),
+			}),
+		},
+		clause.Returning{},
+	).Create(item).Error
+}
+
+func (r gormCartItems) DeleteFromCart(userID uint, ids []uint) (int64, error) {
+	result := r.db.Where("user_id = ?", userID).Delete(&models.CartItem{}, ids)
+	return result.RowsAffected, result.Error
+}
+
+type gormOrders struct{ db *gorm.DB }
+
+func (r gormOrders) FindByID(id uint) (models.Order, error) {
+	var order models.Order
+	err := r.db.Preload("User").Preload("Items").First(&order, id).Error
+	return order, err
+}
+
+func (r gormOrders) FindForUpdate(id uint) (models.Order, error) {
+	var order models.Order
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+		return order, err
+	}
+	err := r.db.Where("order_id = ?", order.ID).Find(&order.Items).Error
+	return order, err
+}
+
+func (r gormOrders) ListByUser(userID uint, status models.OrderStatus) ([]models.Order, error) {
+	query := r.db.Preload("User").Preload("Items").Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.Order
+	err := query.Order("created_at DESC").Find(&orders).Error
+	return orders, err
+}
+
+func (r gormOrders) Create(order *models.Order) error {
+	return r.db.Omit("User").Create(order).Error
+}
+
+func (r gormOrders) AddLine(order *models.Order, line *models.OrderLine) error {
+	line.OrderID = order.ID
+	if err := r.db.Create(line).Error; err != nil {
+		return err
+	}
+	if err := r.db.Model(order).Update("total", gorm.Expr("total + ?", line.Subtotal)).Error; err != nil {
+		return err
+	}
+	// La orden está bloqueada por FindForUpdate, así que el total en memoria
+	// coincide con el de la fila
+	order.Total += line.Subtotal
+	order.Items = append(order.Items, *line)
+	return nil
+}
+
+func (r gormOrders) UpdateStatus(order *models.Order, status models.OrderStatus) error {
+	return r.db.Model(order).Update("status", status).Error
+}
+
+type gormInventory struct{ db *gorm.DB }
+
+func (r gormInventory) FindByProductID(productID uint) (models.Inventory, error) {
+	var inventory models.Inventory
+	err := r.db.Where("product_id = ?", productID).First(&inventory).Error
+	return inventory, err
+}
+
+func (r gormInventory) LockByProductIDs(productIDs []uint) ([]models.Inventory, error) {
+	var inventories []models.Inventory
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id IN ?", productIDs).
+		Order("product_id").
+		Find(&inventories).Error
+	return inventories, err
+}
+
+func (r gormInventory) LockOrCreate(productID uint) (models.Inventory, error) {
+	inventory := models.Inventory{ProductID: productID}
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).FirstOrCreate(&inventory, "product_id = ?", productID).Error
+	return inventory, err
+}
+
+func (r gormInventory) Save(inventory *models.Inventory) error {
+	return r.db.Save(inventory).Error
+}
+
+func (r gormInventory) DeleteByProductID(productID uint) error {
+	return r.db.Where("product_id = ?", productID).Delete(&models.Inventory{}).Error
+}
+
+type gormOutbox struct{ db *gorm.DB }
+
+func (r gormOutbox) Enqueue(event *models.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
@@ -0,0 +1,610 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+// memoryData son las tablas del store en memoria. Las relaciones se guardan
+// solo como IDs y se cargan al leer, igual que los Preload de GORM.
+type memoryData struct {
+	products   map[uint]models.Product
+	users      map[uint]models.User
+	cartItems  map[uint]models.CartItem
+	orders     map[uint]models.Order
+	orderLines map[uint]models.OrderLine
+	inventory  map[uint]models.Inventory
+	outbox     []models.OutboxEvent
+	lastID     map[string]uint
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		products:   make(map[uint]models.Product),
+		users:      make(map[uint]models.User),
+		cartItems:  make(map[uint]models.CartItem),
+		orders:     make(map[uint]models.Order),
+		orderLines: make(map[uint]models.OrderLine),
+		inventory:  make(map[uint]models.Inventory),
+		lastID:     make(map[string]uint),
+	}
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		products:   copyMap(d.products),
+		users:      copyMap(d.users),
+		cartItems:  copyMap(d.cartItems),
+		orders:     copyMap(d.orders),
+		orderLines: copyMap(d.orderLines),
+		inventory:  copyMap(d.inventory),
+		outbox:     append([]models.OutboxEvent(nil), d.outbox...),
+		lastID:     copyMap(d.lastID),
+	}
+}
+
+func (d *memoryData) nextID(table string) uint {
+	d.lastID[table]++
+	return d.lastID[table]
+}
+
+func sortedKeys[V any](m map[uint]V) []uint {
+	keys := make([]uint, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// cartItem devuelve la línea con su producto cargado.
+func (d *memoryData) cartItem(item models.CartItem) models.CartItem {
+	item.Product = d.products[item.ProductID]
+	return item
+}
+
+// user devuelve el usuario con su carrito cargado.
+func (d *memoryData) user(user models.User) models.User {
+	user.Cart = []models.CartItem{}
+	for _, id := range sortedKeys(d.cartItems) {
+		if item := d.cartItems[id]; item.UserID == user.ID {
+			user.Cart = append(user.Cart, d.cartItem(item))
+		}
+	}
+	return user
+}
+
+// linesOf devuelve las líneas de la orden.
+func (d *memoryData) linesOf(orderID uint) []models.OrderLine {
+	lines := []models.OrderLine{}
+	for _, id := range sortedKeys(d.orderLines) {
+		if line := d.orderLines[id]; line.OrderID == orderID {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// order devuelve la orden con su usuario, sin el carrito, y sus líneas
+// cargados, igual que el store de GORM.
+func (d *memoryData) order(order models.Order) models.Order {
+	order.User = d.users[order.UserID]
+	order.User.Cart = nil
+	order.Items = d.linesOf(order.ID)
+	return order
+}
+
+// memoryState es el estado compartido por todas las copias de un store en
+// memoria. mu serializa las transacciones, lo que equivale a bloquear todas
+// las filas que tocan.
+type memoryState struct {
+	mu   sync.Mutex
+	data *memoryData
+}
+
+// memoryStore implementa Store en memoria, para probar las reglas de negocio
+// sin base de datos. tx es la copia de los datos dentro de una transacción.
+type memoryStore struct {
+	state *memoryState
+	tx    *memoryData
+	ctx   context.Context
+}
+
+// NewMemoryStore devuelve un Store vacío en memoria.
+func NewMemoryStore() Store {
+	return memoryStore{state: &memoryState{data: newMemoryData()}}
+}
+
+// do ejecuta fn sobre los datos: dentro de una transacción usa su copia y
+// fuera de ella bloquea el estado compartido.
+func (s memoryStore) do(fn func(d *memoryData) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	return fn(s.state.data)
+}
+
+func (s memoryStore) Products() ProductRepository    { return memoryProducts{s} }
+func (s memoryStore) Users() UserRepository          { return memoryUsers{s} }
+func (s memoryStore) CartItems() CartItemRepository  { return memoryCartItems{s} }
+func (s memoryStore) Orders() OrderRepository        { return memoryOrders{s} }
+func (s memoryStore) Inventory() InventoryRepository { return memoryInventory{s} }
+func (s memoryStore) Outbox() OutboxRepository       { return memoryOutbox{s} }
+
+func (s memoryStore) WithContext(ctx context.Context) Store {
+	s.ctx = ctx
+	return s
+}
+
+func (s memoryStore) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// Transaction trabaja sobre una copia de los datos y la publica solo si fn
+// termina sin error.
+func (s memoryStore) Transaction(fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	tx := memoryStore{state: s.state, tx: s.state.data.clone(), ctx: s.ctx}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.state.data = tx.tx
+	return nil
+}
+
+type memoryProducts struct{ s memoryStore }
+
+func (r memoryProducts) FindByID(id uint) (product models.Product, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		var ok bool
+		if product, ok = d.products[id]; !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return product, err
+}
+
+func (r memoryProducts) FindByName(name string) (product models.Product, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for _, id := range sortedKeys(d.products) {
+			if d.products[id].Name == name {
+				product = d.products[id]
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return product, err
+}
+
+func (r memoryProducts) NameTaken(name string, exceptID uint) (taken bool, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for id, product := range d.products {
+			if id != exceptID && product.Name == name {
+				taken = true
+			}
+		}
+		return nil
+	})
+	return taken, err
+}
+
+func (r memoryProducts) SKUTaken(sku string, exceptID uint) (taken bool, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for id, product := range d.products {
+			if id != exceptID && product.SKU == sku {
+				taken = true
+			}
+		}
+		return nil
+	})
+	return taken, err
+}
+
+// unique verifica las restricciones de unicidad de la tabla products.
+func (r memoryProducts) unique(d *memoryData, product models.Product) error {
+	for id, other := range d.products {
+		if id == product.ID {
+			continue
+		}
+		if other.Name == product.Name || (product.SKU != "" && other.SKU == product.SKU) {
+			return ErrDuplicatedKey
+		}
+	}
+	return nil
+}
+
+func (r memoryProducts) Create(product *models.Product) error {
+	return r.s.do(func(d *memoryData) error {
+		if product.Currency == "" {
+			product.Currency = models.DefaultCurrency
+		}
+		if err := r.unique(d, *product); err != nil {
+			return err
+		}
+		product.ID = d.nextID("products")
+		d.products[product.ID] = *product
+		return nil
+	})
+}
+
+func (r memoryProducts) Save(product *models.Product) error {
+	return r.s.do(func(d *memoryData) error {
+		if product.ID == 0 {
+			product.ID = d.nextID("products")
+		}
+		if err := r.unique(d, *product); err != nil {
+			return err
+		}
+		d.products[product.ID] = *product
+		return nil
+	})
+}
+
+func (r memoryProducts) Delete(product *models.Product) error {
+	return r.s.do(func(d *memoryData) error {
+		delete(d.products, product.ID)
+		return nil
+	})
+}
+
+type memoryUsers struct{ s memoryStore }
+
+func (r memoryUsers) List() (users []models.User, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for _, id := range sortedKeys(d.users) {
+			users = append(users, d.user(d.users[id]))
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (r memoryUsers) FindByID(id uint) (user models.User, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		stored, ok := d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		user = d.user(stored)
+		return nil
+	})
+	return user, err
+}
+
+func (r memoryUsers) FindByUsername(username string) (user models.User, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for _, id := range sortedKeys(d.users) {
+			if d.users[id].Username == username {
+				user = d.user(d.users[id])
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return user, err
+}
+
+func (r memoryUsers) put(d *memoryData, user *models.User) error {
+	for id, other := range d.users {
+		if id != user.ID && other.Username == user.Username {
+			return ErrDuplicatedKey
+		}
+	}
+	stored := *user
+	stored.Cart = nil
+	d.users[user.ID] = stored
+	return nil
+}
+
+func (r memoryUsers) Create(user *models.User) error {
+	return r.s.do(func(d *memoryData) error {
+		id := d.nextID("users")
+		user.ID = id
+		if err := r.put(d, user); err != nil {
+			user.ID = 0
+			return err
+		}
+		return nil
+	})
+}
+
+func (r memoryUsers) Save(user *models.User) error {
+	return r.s.do(func(d *memoryData) error {
+		if user.ID == 0 {
+			user.ID = d.nextID("users")
+		}
+		return r.put(d, user)
+	})
+}
+
+func (r memoryUsers) Delete(user *models.User) error {
+	return r.s.do(func(d *memoryData) error {
+		delete(d.users, user.ID)
+		return nil
+	})
+}
+
+type memoryCartItems struct{ s memoryStore }
+
+func (r memoryCartItems) FindByID(id uint) (item models.CartItem, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		stored, ok := d.cartItems[id]
+		if !ok {
+			return ErrNotFound
+		}
+		item = d.cartItem(stored)
+		return nil
+	})
+	return item, err
+}
+
+// sameLine indica si a y b son líneas del mismo usuario, producto y variante.
+func sameLine(a, b models.CartItem) bool {
+	return a.UserID == b.UserID && a.ProductID == b.ProductID && a.Variant == b.Variant
+}
+
+func (r memoryCartItems) put(d *memoryData, item *models.CartItem) error {
+	for id, other := range d.cartItems {
+		if id != item.ID && sameLine(other, *item) {
+			return ErrDuplicatedKey
+		}
+	}
+	stored := *item
+	stored.Product = models.Product{}
+	d.cartItems[item.ID] = stored
+	return nil
+}
+
+func (r memoryCartItems) Create(item *models.CartItem) error {
+	return r.s.do(func(d *memoryData) error {
+		item.ID = d.nextID("cart_items")
+		if err := r.put(d, item); err != nil {
+			item.ID = 0
+			return err
+		}
+		return nil
+	})
+}
+
+func (r memoryCartItems) Save(item *models.CartItem) error {
+	return r.s.do(func(d *memoryData) error {
+		if item.ID == 0 {
+			item.ID = d.nextID("cart_items")
+		}
+		return r.put(d, item)
+	})
+}
+
+func (r memoryCartItems) Merge(item *models.CartItem) error {
+	return r.s.do(func(d *memoryData) error {
+		for _, id := range sortedKeys(d.cartItems) {
+			if existing := d.cartItems[id]; sameLine(existing, *item) {
+				existing.Quantity += item.Quantity
+				*item = existing
+				return r.put(d, item)
+			}
+		}
+		item.ID = d.nextID("cart_items")
+		return r.put(d, item)
+	})
+}
+
+func (r memoryCartItems) Delete(item *models.CartItem) error {
+	return r.s.do(func(d *memoryData) error {
+		delete(d.cartItems, item.ID)
+		return nil
+	})
+}
+
+func (r memoryCartItems) DeleteFromCart(userID uint, ids []uint) (deleted int64, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for _, id := range ids {
+			if item, ok := d.cartItems[id]; ok && item.UserID == userID {
+				delete(d.cartItems, id)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+type memoryOrders struct{ s memoryStore }
+
+func (r memoryOrders) FindByID(id uint) (order models.Order, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		stored, ok := d.orders[id]
+		if !ok {
+			return ErrNotFound
+		}
+		order = d.order(stored)
+		return nil
+	})
+	return order, err
+}
+
+func (r memoryOrders) FindForUpdate(id uint) (order models.Order, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		stored, ok := d.orders[id]
+		if !ok {
+			return ErrNotFound
+		}
+		order = stored
+		order.Items = d.linesOf(id)
+		return nil
+	})
+	return order, err
+}
+
+func (r memoryOrders) ListByUser(userID uint, status models.OrderStatus) (orders []models.Order, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		for _, id := range sortedKeys(d.orders) {
+			order := d.orders[id]
+			if order.UserID == userID && (status == "" || order.Status == status) {
+				orders = append(orders, d.order(order))
+			}
+		}
+		return nil
+	})
+	// Las más recientes primero; a igual fecha, la de mayor ID
+	sort.SliceStable(orders, func(i, j int) bool {
+		if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].ID > orders[j].ID
+		}
+		return orders[i].CreatedAt.After(orders[j].CreatedAt)
+	})
+	return orders, err
+}
+
+func (r memoryOrders) put(d *memoryData, order *models.Order) {
+	stored := *order
+	stored.User = models.User{}
+	stored.Items = nil
+	d.orders[order.ID] = stored
+}
+
+func (r memoryOrders) Create(order *models.Order) error {
+	return r.s.do(func(d *memoryData) error {
+		now := time.Now()
+		order.ID = d.nextID("orders")
+		order.CreatedAt, order.UpdatedAt = now, now
+		if order.Status == "" {
+			order.Status = models.OrderPending
+		}
+		if order.Currency == "" {
+			order.Currency = models.DefaultCurrency
+		}
+		for i := range order.Items {
+			order.Items[i].ID = d.nextID("order_lines")
+			order.Items[i].OrderID = order.ID
+			d.orderLines[order.Items[i].ID] = order.Items[i]
+		}
+		r.put(d, order)
+		return nil
+	})
+}
+
+func (r memoryOrders) AddLine(order *models.Order, line *models.OrderLine) error {
+	return r.s.do(func(d *memoryData) error {
+		stored, ok := d.orders[order.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		line.ID = d.nextID("order_lines")
+		line.OrderID = order.ID
+		d.orderLines[line.ID] = *line
+
+		stored.Total += line.Subtotal
+		stored.UpdatedAt = time.Now()
+		d.orders[order.ID] = stored
+		order.Total, order.UpdatedAt = stored.Total, stored.UpdatedAt
+		order.Items = append(order.Items, *line)
+		return nil
+	})
+}
+
+func (r memoryOrders) UpdateStatus(order *models.Order, status models.OrderStatus) error {
+	return r.s.do(func(d *memoryData) error {
+		stored, ok := d.orders[order.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = status
+		stored.UpdatedAt = time.Now()
+		d.orders[order.ID] = stored
+		order.Status, order.UpdatedAt = stored.Status, stored.UpdatedAt
+		return nil
+	})
+}
+
+type memoryInventory struct{ s memoryStore }
+
+func (r memoryInventory) FindByProductID(productID uint) (inventory models.Inventory, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		var ok bool
+		if inventory, ok = d.inventory[productID]; !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return inventory, err
+}
+
+func (r memoryInventory) LockByProductIDs(productIDs []uint) (inventories []models.Inventory, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		ids := append([]uint(nil), productIDs...)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			if inventory, ok := d.inventory[id]; ok {
+				inventories = append(inventories, inventory)
+			}
+		}
+		return nil
+	})
+	return inventories, err
+}
+
+func (r memoryInventory) LockOrCreate(productID uint) (inventory models.Inventory, err error) {
+	err = r.s.do(func(d *memoryData) error {
+		var ok bool
+		if inventory, ok = d.inventory[productID]; !ok {
+			inventory = models.Inventory{ProductID: productID, UpdatedAt: time.Now()}
+			d.inventory[productID] = inventory
+		}
+		return nil
+	})
+	return inventory, err
+}
+
+func (r memoryInventory) Save(inventory *models.Inventory) error {
+	return r.s.do(func(d *memoryData) error {
+		inventory.UpdatedAt = time.Now()
+		d.inventory[inventory.ProductID] = *inventory
+		return nil
+	})
+}
+
+func (r memoryInventory) DeleteByProductID(productID uint) error {
+	return r.s.do(func(d *memoryData) error {
+		delete(d.inventory, productID)
+		return nil
+	})
+}
+
+type memoryOutbox struct{ s memoryStore }
+
+func (r memoryOutbox) Enqueue(event *models.OutboxEvent) error {
+	return r.s.do(func(d *memoryData) error {
+		event.ID = d.nextID("outbox_events")
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+		d.outbox = append(d.outbox, *event)
+		return nil
+	})
+}
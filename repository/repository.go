@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound indica que el registro buscado no existe. Es el mismo error de
+// GORM para que ambas implementaciones se comparen igual con errors.Is.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrDuplicatedKey indica que se violó una restricción de unicidad.
+var ErrDuplicatedKey = gorm.ErrDuplicatedKey
+
+type ProductRepository interface {
+	FindByID(id uint) (models.Product, error)
+	FindByName(name string) (models.Product, error)
+	// NameTaken indica si otro producto distinto de exceptID usa el nombre.
+	NameTaken(name string, exceptID uint) (bool, error)
+	// SKUTaken indica si otro producto distinto de exceptID usa el SKU.
+	SKUTaken(sku string, exceptID uint) (bool, error)
+	Create(product *models.Product) error
+	Save(product *models.Product) error
+	Delete(product *models.Product) error
+}
+
+// UserRepository devuelve los usuarios con su carrito y los productos de
+// cada línea cargados. Save no modifica el carrito.
+type UserRepository interface {
+	List() ([]models.User, error)
+	FindByID(id uint) (models.User, error)
+	FindByUsername(username string) (models.User, error)
+	Create(user *models.User) error
+	Save(user *models.User) error
+	Delete(user *models.User) error
+}
+
+// CartItemRepository devuelve las líneas con su producto cargado. Create y
+// Save devuelven ErrDuplicatedKey si el usuario ya tiene otra línea del mismo
+// producto y variante.
+type CartItemRepository interface {
+	FindByID(id uint) (models.CartItem, error)
+	Create(item *models.CartItem) error
+	Save(item *models.CartItem) error
+	Delete(item *models.CartItem) error
+	// Merge crea la línea o, si el usuario ya tiene una del mismo producto y
+	// variante, le suma item.Quantity. Al volver, item tiene el ID y la
+	// cantidad que quedó en el carrito.
+	Merge(item *models.CartItem) error
+	// DeleteFromCart elimina las líneas ids del carrito de userID y devuelve
+	// cuántas eliminó.
+	DeleteFromCart(userID uint, ids []uint) (int64, error)
+}
+
+// OrderRepository devuelve las órdenes con sus líneas y su usuario cargados.
+type OrderRepository interface {
+	FindByID(id uint) (models.Order, error)
+	// FindForUpdate bloquea la orden hasta el fin de la transacción. Carga
+	// las líneas pero no el usuario.
+	FindForUpdate(id uint) (models.Order, error)
+	// ListByUser devuelve las órdenes del usuario, las más recientes primero.
+	// Con status vacío devuelve todas.
+	ListByUser(userID uint, status models.OrderStatus) ([]models.Order, error)
+	// Create guarda la orden junto con sus líneas.
+	Create(order *models.Order) error
+	// AddLine agrega la línea a la orden y suma su subtotal al total.
+	AddLine(order *models.Order, line *models.OrderLine) error
+	UpdateStatus(order *models.Order, status models.OrderStatus) error
+}
+
+type InventoryRepository interface {
+	FindByProductID(productID uint) (models.Inventory, error)
+	// LockByProductIDs bloquea las filas de inventario de los productos,
+	// siempre en orden de producto. Los productos sin fila no aparecen.
+	LockByProductIDs(productIDs []uint) ([]models.Inventory, error)
+	// LockOrCreate bloquea la fila de inventario del producto, creándola si
+	// no existe.
+	LockOrCreate(productID uint) (models.Inventory, error)
+	Save(inventory *models.Inventory) error
+	DeleteByProductID(productID uint) error
+}
+
+type OutboxRepository interface {
+	Enqueue(event *models.OutboxEvent) error
+}
+
+// Store agrupa los repositorios. Los repositorios de un Store obtenido en
+// Transaction comparten la transacción.
+type Store interface {
+	Products() ProductRepository
+	Users() UserRepository
+	CartItems() CartItemRepository
+	Orders() OrderRepository
+	Inventory() InventoryRepository
+	Outbox() OutboxRepository
+
+	// WithContext devuelve un Store cuyas operaciones usan ctx.
+	WithContext(ctx context.Context) Store
+	// Context devuelve el contexto con el que se creó el Store.
+	Context() context.Context
+	// Transaction ejecuta fn en una transacción: si fn devuelve error o hace
+	// panic, se deshacen todos sus cambios.
+	Transaction(fn func(tx Store) error) error
+}
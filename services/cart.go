@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+var (
+	ErrCartItemNotFound = apperrors.New(apperrors.CodeCartItemNotFound, "cart item not found")
+	ErrMixedCurrency    = apperrors.New(apperrors.CodeMixedCurrency, "cart contains products with different currencies")
+)
+
+// CartService administra las líneas del carrito de cada usuario.
+type CartService struct {
+	store repository.Store
+}
+
+// summarizeCart calcula subtotales, cantidad de ítems y total de las líneas
+// dadas. Es la misma regla que usa el checkout para el total de la orden.
+// Los CartItem deben venir con Product precargado.
+func summarizeCart(items []models.CartItem) (models.CartSummary, error) {
+	summary := models.CartSummary{
+		Lines:    make([]models.CartSummaryLine, 0, len(items)),
+		Currency: models.DefaultCurrency,
+	}
+
+	for i, item := range items {
+		if i == 0 {
+			summary.Currency = item.Product.Currency
+		} else if item.Product.Currency != summary.Currency {
+			return summary, fmt.Errorf("%w: %s and %s", ErrMixedCurrency, summary.Currency, item.Product.Currency)
+		}
+
+		line := models.CartSummaryLine{
+			CartItemID:  item.ID,
+			ProductID:   item.ProductID,
+			ProductName: item.Product.Name,
+			Variant:     item.Variant,
+			UnitPrice:   item.Product.Price,
+			Quantity:    item.Quantity,
+			Subtotal:    item.Product.Price * int64(item.Quantity),
+		}
+		summary.Lines = append(summary.Lines, line)
+		summary.ItemCount += item.Quantity
+		summary.Total += line.Subtotal
+	}
+
+	return summary, nil
+}
+
+func cartItemEvent(item models.CartItem) models.CartItemEvent {
+	return models.CartItemEvent{
+		UserID:     item.UserID,
+		CartItemID: item.ID,
+		ProductID:  item.ProductID,
+		Variant:    item.Variant,
+		Quantity:   item.Quantity,
+	}
+}
+
+// addToCart suma quantity unidades de product en la variante dada al carrito
+// de user. Si el usuario ya tiene una línea del mismo producto y variante, la
+// cantidad se acumula en ella. El evento informa solo las unidades agregadas.
+func addToCart(tx repository.Store, user models.User, product models.Product, variant string, quantity int) (models.CartItem, error) {
+	if err := checkActive(product); err != nil {
+		return models.CartItem{}, err
+	}
+	if err := checkPositive(product, quantity); err != nil {
+		return models.CartItem{}, err
+	}
+
+	cartItem := models.CartItem{
+		ProductID: product.ID,
+		UserID:    user.ID,
+		Variant:   variant,
+		Quantity:  quantity,
+	}
+	if err := tx.CartItems().Merge(&cartItem); err != nil {
+		return models.CartItem{}, err
+	}
+
+	// Verificar los límites del producto y el stock para la cantidad que quedó
+	// en el carrito; si no se cumplen, la transacción deshace el cambio
+	if err := checkQuantity(product, cartItem.Quantity); err != nil {
+		return models.CartItem{}, err
+	}
+	if err := checkStock(tx, product.ID, cartItem.Quantity); err != nil {
+		return models.CartItem{}, err
+	}
+
+	added := cartItemEvent(cartItem)
+	added.Quantity = quantity
+	if err := enqueueEvent(tx, models.EventCartItemAdded, added); err != nil {
+		return models.CartItem{}, err
+	}
+
+	cartItem.Product = product
+	return cartItem, nil
+}
+
+func (s *CartService) Item(ctx context.Context, cartItemID uint) (models.CartItem, error) {
+	item, err := s.store.WithContext(ctx).CartItems().FindByID(cartItemID)
+	return item, notFound(err, ErrCartItemNotFound)
+}
+
+// ItemOwner devuelve el username dueño de una línea de carrito.
+func (s *CartService) ItemOwner(ctx context.Context, cartItemID uint) (string, error) {
+	store := s.store.WithContext(ctx)
+
+	item, err := store.CartItems().FindByID(cartItemID)
+	if err != nil {
+		return "", notFound(err, ErrCartItemNotFound)
+	}
+	user, err := store.Users().FindByID(item.UserID)
+	if err != nil {
+		return "", notFound(err, ErrCartItemNotFound)
+	}
+	return user.Username, nil
+}
+
+// AddItem agrega quantity unidades del producto productName en la variante
+// dada al carrito del usuario. Si el producto no existe en el catálogo, se crea.
+func (s *CartService) AddItem(ctx context.Context, username, productName, variant string, quantity int) (models.CartItem, error) {
+	var cartItem models.CartItem
+
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		user, err := tx.Users().FindByUsername(username)
+		if err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+
+		product, err := findOrCreateProduct(tx, productName)
+		if err != nil {
+			return err
+		}
+
+		cartItem, err = addToCart(tx, user, product, variant, quantity)
+		return err
+	})
+
+	return cartItem, err
+}
+
+// AddProduct agrega quantity unidades de un producto existente en la
+// variante dada al carrito del usuario userID.
+func (s *CartService) AddProduct(ctx context.Context, userID, productID uint, variant string, quantity int) (models.CartItem, error) {
+	var cartItem models.CartItem
+
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		user, err := tx.Users().FindByID(userID)
+		if err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+
+		product, err := tx.Products().FindByID(productID)
+		if err != nil {
+			return notFound(err, ErrProductNotFound)
+		}
+
+		cartItem, err = addToCart(tx, user, product, variant, quantity)
+		return err
+	})
+
+	return cartItem, err
+}
+
+// UpdateItem fija la cantidad de una línea del carrito. Si productID no es
+// cero, la línea se quita y se agrega la de ese producto; si el usuario ya
+// tenía una línea de ese producto y variante, ambas se juntan. Una cantidad
+// cero elimina la línea y se devuelve con Quantity en cero.
+func (s *CartService) UpdateItem(ctx context.Context, cartItemID uint, quantity int, productID uint) (models.CartItem, error) {
+	var cartItem models.CartItem
+
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		var err error
+		cartItem, err = tx.CartItems().FindByID(cartItemID)
+		if err != nil {
+			return notFound(err, ErrCartItemNotFound)
+		}
+
+		if quantity < 0 {
+			return invalidQuantity(cartItem.Product, quantity, "quantity must not be negative")
+		}
+		if quantity == 0 {
+			cartItem.Quantity = 0
+			return removeItem(tx, cartItem)
+		}
+
+		if productID != 0 && productID != cartItem.ProductID {
+			product, err := tx.Products().FindByID(productID)
+			if err != nil {
+				return notFound(err, ErrProductNotFound)
+			}
+			if err := removeItem(tx, cartItem); err != nil {
+				return err
+			}
+			cartItem, err = addToCart(tx, models.User{ID: cartItem.UserID}, product, cartItem.Variant, quantity)
+			return err
+		}
+
+		previous := cartItem.Quantity
+		if quantity == previous {
+			return nil
+		}
+		cartItem.Quantity = quantity
+
+		// Verificar los límites del producto y el stock para la nueva cantidad
+		if err := checkQuantity(cartItem.Product, cartItem.Quantity); err != nil {
+			return err
+		}
+		if err := checkStock(tx, cartItem.ProductID, cartItem.Quantity); err != nil {
+			return err
+		}
+
+		if err := tx.CartItems().Save(&cartItem); err != nil {
+			return fmt.Errorf("Error updating CartItem quantity: %w", err)
+		}
+
+		updated := cartItemEvent(cartItem)
+		updated.PreviousQuantity = previous
+		return enqueueEvent(tx, models.EventCartItemUpdated, updated)
+	})
+
+	return cartItem, err
+}
+
+// removeItem elimina la línea junto con su evento.
+func removeItem(tx repository.Store, cartItem models.CartItem) error {
+	if err := tx.CartItems().Delete(&cartItem); err != nil {
+		return fmt.Errorf("Error deleting CartItem: %w", err)
+	}
+	return enqueueEvent(tx, models.EventCartItemRemoved, cartItemEvent(cartItem))
+}
+
+// RemoveItem elimina la línea cartItemID del carrito del usuario y devuelve
+// el usuario con el carrito actualizado.
+func (s *CartService) RemoveItem(ctx context.Context, username string, cartItemID uint) (models.User, error) {
+	var user models.User
+
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		var err error
+		user, err = tx.Users().FindByUsername(username)
+		if err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+
+		for i, item := range user.Cart {
+			if item.ID == cartItemID {
+				user.Cart = append(user.Cart[:i], user.Cart[i+1:]...)
+				return removeItem(tx, item)
+			}
+		}
+		return ErrCartItemNotFound
+	})
+
+	return user, err
+}
+
+// DeleteItem elimina una línea del carrito a partir de su ID.
+func (s *CartService) DeleteItem(ctx context.Context, cartItemID uint) error {
+	return s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		cartItem, err := tx.CartItems().FindByID(cartItemID)
+		if err != nil {
+			return notFound(err, ErrCartItemNotFound)
+		}
+		return removeItem(tx, cartItem)
+	})
+}
+
+func (s *CartService) Summary(ctx context.Context, username string) (models.CartSummary, error) {
+	user, err := s.store.WithContext(ctx).Users().FindByUsername(username)
+	if err != nil {
+		return models.CartSummary{}, notFound(err, ErrUserNotFound)
+	}
+
+	summary, err := summarizeCart(user.Cart)
+	if err != nil {
+		return models.CartSummary{}, err
+	}
+	summary.Username = user.Username
+
+	return summary, nil
+}
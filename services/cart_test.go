@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+func TestAddProductMergesSameLine(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+
+	first := addToCartOK(t, s, user, product, "M", 2)
+	second := addToCartOK(t, s, user, product, "M", 3)
+	if second.ID != first.ID || second.Quantity != 5 {
+		t.Errorf("second add = line %d x%d; want line %d x5", second.ID, second.Quantity, first.ID)
+	}
+
+	// Otra variante es otra línea
+	other := addToCartOK(t, s, user, product, "L", 1)
+	if other.ID == first.ID {
+		t.Errorf("variant L was merged into line %d", first.ID)
+	}
+
+	if cart := cartOf(t, s, user); len(cart) != 2 {
+		t.Errorf("cart has %d lines; want 2", len(cart))
+	}
+}
+
+func TestUpdateItemMergesOnProductSwap(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	polera := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	gorro := createProduct(t, s, "Gorro", 500, models.ProductInput{})
+
+	line := addToCartOK(t, s, user, polera, "", 2)
+	existing := addToCartOK(t, s, user, gorro, "", 1)
+
+	updated, err := s.Cart.UpdateItem(context.Background(), line.ID, 4, gorro.ID)
+	if err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if updated.ID != existing.ID || updated.Quantity != 5 || updated.Product.ID != gorro.ID {
+		t.Errorf("UpdateItem = line %d product %d x%d; want line %d product %d x5",
+			updated.ID, updated.Product.ID, updated.Quantity, existing.ID, gorro.ID)
+	}
+
+	cart := cartOf(t, s, user)
+	if len(cart) != 1 || cart[0].ID != existing.ID {
+		t.Errorf("cart = %+v; want only line %d", cart, existing.ID)
+	}
+}
+
+func TestQuantityRules(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	product := createProduct(t, s, "Tornillo", 10, models.ProductInput{
+		MinQuantity:  ptr(2),
+		MaxQuantity:  ptr(10),
+		QuantityStep: ptr(2),
+	})
+
+	for _, quantity := range []int{-2, 0, 1, 3, 12} {
+		_, err := s.Cart.AddProduct(context.Background(), user.ID, product.ID, "", quantity)
+		if !errors.Is(err, ErrInvalidQuantity) {
+			t.Errorf("AddProduct x%d error = %v; want %v", quantity, err, ErrInvalidQuantity)
+		}
+	}
+
+	line := addToCartOK(t, s, user, product, "", 4)
+
+	// Lo que se valida es la cantidad acumulada en la línea
+	_, err := s.Cart.AddProduct(context.Background(), user.ID, product.ID, "", 8)
+	expectError(t, err, ErrInvalidQuantity)
+
+	for _, quantity := range []int{-1, 5, 12} {
+		_, err := s.Cart.UpdateItem(context.Background(), line.ID, quantity, 0)
+		if !errors.Is(err, ErrInvalidQuantity) {
+			t.Errorf("UpdateItem x%d error = %v; want %v", quantity, err, ErrInvalidQuantity)
+		}
+	}
+
+	updated, err := s.Cart.UpdateItem(context.Background(), line.ID, 6, 0)
+	if err != nil {
+		t.Fatalf("UpdateItem x6: %v", err)
+	}
+	if updated.Quantity != 6 {
+		t.Errorf("quantity = %d; want 6", updated.Quantity)
+	}
+
+	// Una cantidad cero quita la línea
+	removed, err := s.Cart.UpdateItem(context.Background(), line.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("UpdateItem x0: %v", err)
+	}
+	if removed.Quantity != 0 {
+		t.Errorf("quantity = %d; want 0", removed.Quantity)
+	}
+	_, err = s.Cart.Item(context.Background(), line.ID)
+	expectError(t, err, ErrCartItemNotFound)
+}
+
+func TestAddProductRejectsInactiveProduct(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{Active: ptr(false)})
+
+	_, err := s.Cart.AddProduct(context.Background(), user.ID, product.ID, "", 1)
+	expectError(t, err, ErrProductInactive)
+
+	if cart := cartOf(t, s, user); len(cart) != 0 {
+		t.Errorf("cart has %d lines; want 0", len(cart))
+	}
+}
+
+func TestAddProductChecksStock(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	setStock(t, s, product, 3)
+
+	addToCartOK(t, s, user, product, "", 2)
+	_, err := s.Cart.AddProduct(context.Background(), user.ID, product.ID, "", 2)
+	expectError(t, err, ErrInsufficientStock)
+
+	// El intento fallido no cambia la línea
+	cart := cartOf(t, s, user)
+	if len(cart) != 1 || cart[0].Quantity != 2 {
+		t.Errorf("cart = %+v; want one line x2", cart)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+var (
+	ErrProductNotFound  = apperrors.New(apperrors.CodeProductNotFound, "product not found")
+	ErrDuplicateProduct = apperrors.New(apperrors.CodeDuplicateProduct, "product with the same name already exists")
+	ErrDuplicateSKU     = apperrors.New(apperrors.CodeDuplicateSKU, "product with the same sku already exists")
+	ErrInvalidProduct   = apperrors.New(apperrors.CodeInvalidProduct, "invalid product")
+	ErrProductInactive  = apperrors.New(apperrors.CodeProductInactive, "product is not available for sale")
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// CatalogService administra los productos y su inventario.
+type CatalogService struct {
+	store repository.Store
+}
+
+// applyProductInput valida los campos recibidos y los copia sobre el producto.
+func applyProductInput(product *models.Product, input models.ProductInput) error {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return fmt.Errorf("%w: name is required", ErrInvalidProduct)
+		}
+		product.Name = name
+	}
+	if input.SKU != nil {
+		product.SKU = strings.TrimSpace(*input.SKU)
+	}
+	if input.Description != nil {
+		product.Description = *input.Description
+	}
+	if input.Price != nil {
+		if *input.Price < 0 {
+			return fmt.Errorf("%w: price must not be negative", ErrInvalidProduct)
+		}
+		product.Price = *input.Price
+	}
+	if input.Currency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*input.Currency))
+		if !currencyPattern.MatchString(currency) {
+			return fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidProduct)
+		}
+		product.Currency = currency
+	}
+	if input.Active != nil {
+		product.Active = *input.Active
+	}
+	return applyQuantityLimits(product, input)
+}
+
+// checkActive verifica que el producto esté a la venta.
+func checkActive(product models.Product) error {
+	if product.Active {
+		return nil
+	}
+	return ErrProductInactive.With(
+		fmt.Sprintf("product %s is not available for sale", product.Name),
+		apperrors.Details{"productID": product.ID},
+	)
+}
+
+// checkProductUnique verifica que ningún otro producto use el mismo nombre o SKU.
+func checkProductUnique(tx repository.Store, product models.Product) error {
+	if taken, err := tx.Products().NameTaken(product.Name, product.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrDuplicateProduct
+	}
+
+	if product.SKU == "" {
+		return nil
+	}
+	if taken, err := tx.Products().SKUTaken(product.SKU, product.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrDuplicateSKU
+	}
+	return nil
+}
+
+// duplicateProduct traduce la violación de unicidad de un producto que pasó
+// checkProductUnique por una carrera con otra transacción.
+func duplicateProduct(err error) error {
+	if errors.Is(err, repository.ErrDuplicatedKey) {
+		return ErrDuplicateProduct
+	}
+	return err
+}
+
+// findOrCreateProduct busca el producto por nombre y, si no existe, lo crea
+// con los valores por defecto del catálogo.
+func findOrCreateProduct(tx repository.Store, name string) (models.Product, error) {
+	product, err := tx.Products().FindByName(name)
+	if err == nil || !errors.Is(err, repository.ErrNotFound) {
+		return product, err
+	}
+
+	product = models.Product{
+		Name:         name,
+		Currency:     models.DefaultCurrency,
+		Active:       true,
+		MinQuantity:  1,
+		QuantityStep: 1,
+	}
+	if err := tx.Products().Create(&product); err != nil {
+		return models.Product{}, fmt.Errorf("Error creating product: %w", err)
+	}
+	return product, nil
+}
+
+func (s *CatalogService) Product(ctx context.Context, name string) (models.Product, error) {
+	product, err := s.store.WithContext(ctx).Products().FindByName(name)
+	return product, notFound(err, ErrProductNotFound)
+}
+
+func (s *CatalogService) CreateProduct(ctx context.Context, input models.ProductInput) (models.Product, error) {
+	// Crea un nuevo producto con los valores proporcionados
+	newProduct := models.Product{
+		Currency:     models.DefaultCurrency,
+		Active:       true,
+		MinQuantity:  1,
+		QuantityStep: 1,
+	}
+	if err := applyProductInput(&newProduct, input); err != nil {
+		return models.Product{}, err
+	}
+	if newProduct.Name == "" {
+		return models.Product{}, fmt.Errorf("%w: name is required", ErrInvalidProduct)
+	}
+
+	// Verifica y crea el producto en una transacción
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		if err := checkProductUnique(tx, newProduct); err != nil {
+			return err
+		}
+		return duplicateProduct(tx.Products().Create(&newProduct))
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	return newProduct, nil
+}
+
+func (s *CatalogService) UpdateProduct(ctx context.Context, name string, input models.ProductInput) (models.Product, error) {
+	var producto models.Product
+
+	// Actualiza el producto y encola su evento en una sola transacción
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		// Consulta la base de datos para obtener el producto existente por su nombre
+		var err error
+		producto, err = tx.Products().FindByName(name)
+		if err != nil {
+			return notFound(err, ErrProductNotFound)
+		}
+
+		// Actualiza los campos del producto existente con los nuevos valores
+		if err := applyProductInput(&producto, input); err != nil {
+			return err
+		}
+
+		// Verifica que el nuevo nombre o SKU no pertenezcan a otro producto
+		if err := checkProductUnique(tx, producto); err != nil {
+			return err
+		}
+
+		// Guarda los cambios en la base de datos
+		if err := tx.Products().Save(&producto); err != nil {
+			return duplicateProduct(err)
+		}
+
+		return enqueueEvent(tx, models.EventProductUpdated, producto)
+	})
+
+	return producto, err
+}
+
+// DeleteProduct elimina el producto junto con su inventario.
+func (s *CatalogService) DeleteProduct(ctx context.Context, name string) error {
+	return s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		product, err := tx.Products().FindByName(name)
+		if err != nil {
+			return notFound(err, ErrProductNotFound)
+		}
+
+		if err := tx.Inventory().DeleteByProductID(product.ID); err != nil {
+			return err
+		}
+
+		return tx.Products().Delete(&product)
+	})
+}
+
+func (s *CatalogService) Inventory(ctx context.Context, productName string) (models.Inventory, error) {
+	store := s.store.WithContext(ctx)
+
+	product, err := store.Products().FindByName(productName)
+	if err != nil {
+		return models.Inventory{}, notFound(err, ErrProductNotFound)
+	}
+
+	inventory, err := store.Inventory().FindByProductID(product.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Inventory{}, fmt.Errorf("%w: stock is not tracked for product %s", ErrProductNotFound, productName)
+		}
+		return models.Inventory{}, err
+	}
+
+	return inventory, nil
+}
+
+// SetInventory fija el stock disponible de un producto, creando la fila de
+// inventario si el producto aún no controlaba stock. Las unidades reservadas
+// no se modifican.
+func (s *CatalogService) SetInventory(ctx context.Context, productName string, available int) (models.Inventory, error) {
+	if available < 0 {
+		return models.Inventory{}, ErrInvalidStock
+	}
+
+	var inventory models.Inventory
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		product, err := tx.Products().FindByName(productName)
+		if err != nil {
+			return notFound(err, ErrProductNotFound)
+		}
+
+		inventory, err = tx.Inventory().LockOrCreate(product.ID)
+		if err != nil {
+			return err
+		}
+
+		inventory.Available = available
+		return tx.Inventory().Save(&inventory)
+	})
+	if err != nil {
+		return models.Inventory{}, err
+	}
+
+	return inventory, nil
+}
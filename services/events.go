@@ -0,0 +1,67 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+	"github.com/FelipeGeraldoblufus/Cart/tracing"
+)
+
+// eventSource identifica a este servicio en los eventos publicados.
+const eventSource = "cart-service"
+
+// newEventID genera un UUID v4.
+func newEventID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// enqueueEvent guarda un evento en el outbox usando la transacción tx. El
+// evento se publica después del commit; si la transacción se deshace, el
+// evento nunca sale.
+func enqueueEvent(tx repository.Store, eventType string, data interface{}) error {
+	id, err := newEventID()
+	if err != nil {
+		return err
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := json.Marshal(models.EventEnvelope{
+		ID:         id,
+		Type:       eventType,
+		Version:    models.EventSchemaVersion,
+		Source:     eventSource,
+		OccurredAt: time.Now().UTC(),
+		Data:       dataJSON,
+	})
+	if err != nil {
+		return err
+	}
+
+	// El relay publica el evento en otra traza de ejecución; se guarda el
+	// contexto de traza para que el evento quede asociado a esta operación
+	traceContext, err := json.Marshal(tracing.Inject(tx.Context()))
+	if err != nil {
+		return err
+	}
+
+	return tx.Outbox().Enqueue(&models.OutboxEvent{
+		EventID:      id,
+		Type:         eventType,
+		Envelope:     envelope,
+		TraceContext: traceContext,
+	})
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+var (
+	ErrEmptyCart         = apperrors.New(apperrors.CodeEmptyCart, "cannot create order with an empty cart")
+	ErrCartChanged       = apperrors.New(apperrors.CodeCartChanged, "cart changed during checkout")
+	ErrOrderNotFound     = apperrors.New(apperrors.CodeOrderNotFound, "order not found")
+	ErrOrderUserMismatch = apperrors.New(apperrors.CodeOrderUserMismatch, "order does not belong to the cart item's user")
+	ErrInvalidStatus     = apperrors.New(apperrors.CodeInvalidOrderStatus, "invalid order status")
+	ErrInvalidTransition = apperrors.New(apperrors.CodeInvalidTransition, "invalid order status transition")
+)
+
+// OrderService administra el checkout y el ciclo de vida de las órdenes.
+type OrderService struct {
+	store repository.Store
+}
+
+// newOrderLine copia el producto, precio y cantidad de una línea del carrito.
+// El CartItem debe venir con Product precargado.
+func newOrderLine(item models.CartItem) models.OrderLine {
+	return models.OrderLine{
+		ProductID:   item.ProductID,
+		ProductName: item.Product.Name,
+		Variant:     item.Variant,
+		UnitPrice:   item.Product.Price,
+		Quantity:    item.Quantity,
+		Subtotal:    item.Product.Price * int64(item.Quantity),
+	}
+}
+
+// selectCartItems devuelve las líneas del carrito indicadas por ids, o todo el
+// carrito si no se indica ninguna.
+func selectCartItems(cart []models.CartItem, ids []uint) ([]models.CartItem, error) {
+	if len(ids) == 0 {
+		return cart, nil
+	}
+
+	byID := make(map[uint]models.CartItem, len(cart))
+	for _, item := range cart {
+		byID[item.ID] = item
+	}
+
+	selected := make([]models.CartItem, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		item, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: CartItem with ID %d not found in user's cart", ErrCartItemNotFound, id)
+		}
+		seen[id] = true
+		selected = append(selected, item)
+	}
+
+	return selected, nil
+}
+
+// Owner devuelve el username dueño de una orden.
+func (s *OrderService) Owner(ctx context.Context, orderID uint) (string, error) {
+	order, err := s.store.WithContext(ctx).Orders().FindByID(orderID)
+	if err != nil {
+		return "", notFound(err, ErrOrderNotFound)
+	}
+	return order.User.Username, nil
+}
+
+// Create realiza el checkout en una sola transacción: copia las líneas
+// seleccionadas del carrito (todas si cartItemIDs está vacío) como líneas de la
+// orden y las elimina del carrito.
+func (s *OrderService) Create(ctx context.Context, username string, cartItemIDs []uint) (models.Order, error) {
+	store := s.store.WithContext(ctx)
+	var order models.Order
+
+	err := store.Transaction(func(tx repository.Store) error {
+		user, err := tx.Users().FindByUsername(username)
+		if err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+
+		// Verificar si el carrito del usuario está vacío
+		if len(user.Cart) == 0 {
+			return ErrEmptyCart
+		}
+
+		// Seleccionar las líneas del carrito que forman parte de la orden
+		selected, err := selectCartItems(user.Cart, cartItemIDs)
+		if err != nil {
+			return err
+		}
+
+		// El total se calcula con la misma regla que el resumen del carrito
+		summary, err := summarizeCart(selected)
+		if err != nil {
+			return err
+		}
+
+		order = models.Order{
+			UserID:   user.ID,
+			Items:    make([]models.OrderLine, 0, len(selected)),
+			Total:    summary.Total,
+			Currency: summary.Currency,
+		}
+		ids := make([]uint, 0, len(selected))
+		for _, item := range selected {
+			// Un producto que se retiró de la venta no se puede comprar
+			if err := checkActive(item.Product); err != nil {
+				return err
+			}
+			order.Items = append(order.Items, newOrderLine(item))
+			ids = append(ids, item.ID)
+		}
+
+		// Reservar el stock de las líneas; las filas de inventario quedan
+		// bloqueadas hasta el commit, por lo que dos checkouts no pueden vender
+		// la misma unidad
+		if err := reserveStock(tx, order.Items); err != nil {
+			return err
+		}
+
+		// Crear la orden junto con sus líneas
+		if err := tx.Orders().Create(&order); err != nil {
+			return err
+		}
+
+		// Quitar del carrito las líneas compradas, con sus eventos. Si otra
+		// transacción ya las eliminó, el checkout concurrente se descarta.
+		deleted, err := tx.CartItems().DeleteFromCart(user.ID, ids)
+		if err != nil {
+			return err
+		}
+		if deleted != int64(len(ids)) {
+			return ErrCartChanged
+		}
+		for _, item := range selected {
+			if err := enqueueEvent(tx, models.EventCartItemRemoved, cartItemEvent(item)); err != nil {
+				return err
+			}
+		}
+
+		return enqueueEvent(tx, models.EventOrderCreated, order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return store.Orders().FindByID(order.ID)
+}
+
+// ByUsername devuelve las órdenes del usuario, las más recientes primero. Si
+// status no está vacío, solo se devuelven las órdenes en ese estado.
+func (s *OrderService) ByUsername(ctx context.Context, username string, status string) ([]models.Order, error) {
+	store := s.store.WithContext(ctx)
+
+	user, err := store.Users().FindByUsername(username)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	if status != "" && !models.OrderStatus(status).Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+
+	return store.Orders().ListByUser(user.ID, models.OrderStatus(status))
+}
+
+// UpdateStatus cambia el estado de una orden si la transición es válida.
+// Al cancelar se libera el stock reservado y al despachar se descuenta de las
+// unidades reservadas.
+func (s *OrderService) UpdateStatus(ctx context.Context, orderID uint, status models.OrderStatus) (models.Order, error) {
+	if !status.Valid() {
+		return models.Order{}, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+
+	store := s.store.WithContext(ctx)
+	var order models.Order
+	err := store.Transaction(func(tx repository.Store) error {
+		// Bloquea la orden para que dos cambios de estado no se crucen
+		var err error
+		order, err = tx.Orders().FindForUpdate(orderID)
+		if err != nil {
+			return notFound(err, ErrOrderNotFound)
+		}
+
+		if !order.Status.CanTransitionTo(status) {
+			return ErrInvalidTransition.With(
+				fmt.Sprintf("invalid order status transition: %s -> %s", order.Status, status),
+				apperrors.Details{"from": order.Status, "to": status},
+			)
+		}
+
+		switch status {
+		case models.OrderCancelled:
+			if err := releaseStock(tx, order.Items); err != nil {
+				return err
+			}
+		case models.OrderShipped:
+			if err := commitStock(tx, order.Items); err != nil {
+				return err
+			}
+		}
+
+		previous := order.Status
+		if err := tx.Orders().UpdateStatus(&order, status); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, models.EventOrderStatusChanged, models.OrderStatusChangedEvent{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			From:    previous,
+			To:      status,
+		})
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	// FindForUpdate no carga el usuario; se vuelve a leer como en Create
+	return store.Orders().FindByID(order.ID)
+}
+
+func (s *OrderService) Cancel(ctx context.Context, orderID uint) (models.Order, error) {
+	return s.UpdateStatus(ctx, orderID, models.OrderCancelled)
+}
+
+// AddCartItem mueve una línea del carrito a una orden pendiente del mismo
+// usuario, dentro de una transacción.
+func (s *OrderService) AddCartItem(ctx context.Context, cartItemID uint, orderID uint) error {
+	return s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		cartItem, err := tx.CartItems().FindByID(cartItemID)
+		if err != nil {
+			return notFound(err, ErrCartItemNotFound)
+		}
+
+		// Buscar la orden y verificar que pertenezca al dueño del carrito
+		order, err := tx.Orders().FindForUpdate(orderID)
+		if err != nil {
+			return notFound(err, ErrOrderNotFound)
+		}
+		if order.UserID != cartItem.UserID {
+			return ErrOrderUserMismatch
+		}
+		if order.Status != models.OrderPending {
+			return fmt.Errorf("%w: cannot add items to a %s order", ErrInvalidTransition, order.Status)
+		}
+		if err := checkActive(cartItem.Product); err != nil {
+			return err
+		}
+		if order.Currency != cartItem.Product.Currency {
+			return fmt.Errorf("%w: %s and %s", ErrMixedCurrency, order.Currency, cartItem.Product.Currency)
+		}
+
+		line := newOrderLine(cartItem)
+		if err := reserveStock(tx, []models.OrderLine{line}); err != nil {
+			return err
+		}
+
+		// Agregar la línea y sumar su subtotal al total de la orden
+		if err := tx.Orders().AddLine(&order, &line); err != nil {
+			return fmt.Errorf("Error creating order line: %w", err)
+		}
+		err = enqueueEvent(tx, models.EventOrderLineAdded, models.OrderLineAddedEvent{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			Line:    line,
+			Total:   order.Total,
+		})
+		if err != nil {
+			return err
+		}
+
+		return removeItem(tx, cartItem)
+	})
+}
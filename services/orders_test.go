@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+func TestCreateOrderCheckout(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	polera := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	gorro := createProduct(t, s, "Gorro", 500, models.ProductInput{})
+	setStock(t, s, polera, 10)
+
+	addToCartOK(t, s, user, polera, "M", 3)
+	addToCartOK(t, s, user, gorro, "", 2)
+
+	order, err := s.Orders.Create(context.Background(), "ana", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if order.Status != models.OrderPending {
+		t.Errorf("status = %s; want %s", order.Status, models.OrderPending)
+	}
+	if order.Total != 4000 {
+		t.Errorf("total = %d; want 4000", order.Total)
+	}
+	if len(order.Items) != 2 {
+		t.Fatalf("order has %d lines; want 2", len(order.Items))
+	}
+	if line := order.Items[0]; line.ProductName != "Polera" || line.Variant != "M" || line.Quantity != 3 || line.Subtotal != 3000 {
+		t.Errorf("first line = %+v; want Polera M x3 for 3000", line)
+	}
+
+	if cart := cartOf(t, s, user); len(cart) != 0 {
+		t.Errorf("cart has %d lines after checkout; want 0", len(cart))
+	}
+	// El checkout reserva el stock de los productos con inventario
+	checkStockLevels(t, s, polera, 7, 3)
+}
+
+func TestCreateOrderSelectedItems(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	polera := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	gorro := createProduct(t, s, "Gorro", 500, models.ProductInput{})
+
+	bought := addToCartOK(t, s, user, polera, "", 1)
+	kept := addToCartOK(t, s, user, gorro, "", 1)
+
+	order, err := s.Orders.Create(context.Background(), "ana", []uint{bought.ID})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(order.Items) != 1 || order.Total != 1000 {
+		t.Errorf("order = %d lines for %d; want 1 line for 1000", len(order.Items), order.Total)
+	}
+
+	cart := cartOf(t, s, user)
+	if len(cart) != 1 || cart[0].ID != kept.ID {
+		t.Errorf("cart = %+v; want only line %d", cart, kept.ID)
+	}
+
+	_, err = s.Orders.Create(context.Background(), "ana", []uint{bought.ID})
+	expectError(t, err, ErrCartItemNotFound)
+}
+
+func TestCreateOrderEmptyCart(t *testing.T) {
+	s := newTestServices(t)
+	createUser(t, s, "ana")
+
+	_, err := s.Orders.Create(context.Background(), "ana", nil)
+	expectError(t, err, ErrEmptyCart)
+}
+
+func TestCreateOrderInsufficientStockRollsBack(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	polera := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	gorro := createProduct(t, s, "Gorro", 500, models.ProductInput{})
+	setStock(t, s, polera, 5)
+	setStock(t, s, gorro, 5)
+
+	addToCartOK(t, s, user, polera, "", 2)
+	addToCartOK(t, s, user, gorro, "", 4)
+	// El stock baja después de agregar al carrito
+	setStock(t, s, gorro, 3)
+
+	_, err := s.Orders.Create(context.Background(), "ana", nil)
+	expectError(t, err, ErrInsufficientStock)
+
+	// Ni la reserva de la otra línea ni el carrito cambian
+	checkStockLevels(t, s, polera, 5, 0)
+	if cart := cartOf(t, s, user); len(cart) != 2 {
+		t.Errorf("cart has %d lines; want 2", len(cart))
+	}
+}
+
+func TestCreateOrderRejectsInactiveProduct(t *testing.T) {
+	s := newTestServices(t)
+	user := createUser(t, s, "ana")
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	addToCartOK(t, s, user, product, "", 1)
+
+	if _, err := s.Catalog.UpdateProduct(context.Background(), "Polera", models.ProductInput{Active: ptr(false)}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+
+	_, err := s.Orders.Create(context.Background(), "ana", nil)
+	expectError(t, err, ErrProductInactive)
+
+	if cart := cartOf(t, s, user); len(cart) != 1 {
+		t.Errorf("cart has %d lines; want 1", len(cart))
+	}
+}
+
+// checkout crea una orden con quantity unidades de product.
+func checkout(t *testing.T, s *Services, product models.Product, quantity int) models.Order {
+	t.Helper()
+	user := createUser(t, s, "ana")
+	addToCartOK(t, s, user, product, "", quantity)
+
+	order, err := s.Orders.Create(context.Background(), "ana", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return order
+}
+
+func updateStatusOK(t *testing.T, s *Services, order models.Order, status models.OrderStatus) {
+	t.Helper()
+	if _, err := s.Orders.UpdateStatus(context.Background(), order.ID, status); err != nil {
+		t.Fatalf("UpdateStatus(%s): %v", status, err)
+	}
+}
+
+func TestCancelReleasesStock(t *testing.T) {
+	s := newTestServices(t)
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	setStock(t, s, product, 10)
+
+	order := checkout(t, s, product, 4)
+	checkStockLevels(t, s, product, 6, 4)
+
+	cancelled, err := s.Orders.Cancel(context.Background(), order.ID)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.Status != models.OrderCancelled {
+		t.Errorf("status = %s; want %s", cancelled.Status, models.OrderCancelled)
+	}
+	if cancelled.User.Username != "ana" || len(cancelled.Items) != 1 {
+		t.Errorf("cancelled order = user %q with %d lines; want ana with 1 line", cancelled.User.Username, len(cancelled.Items))
+	}
+	checkStockLevels(t, s, product, 10, 0)
+}
+
+func TestShipCommitsStock(t *testing.T) {
+	s := newTestServices(t)
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	setStock(t, s, product, 10)
+
+	order := checkout(t, s, product, 4)
+	updateStatusOK(t, s, order, models.OrderPaid)
+	checkStockLevels(t, s, product, 6, 4)
+
+	updateStatusOK(t, s, order, models.OrderShipped)
+	checkStockLevels(t, s, product, 6, 0)
+
+	// Una orden despachada ya no se puede cancelar
+	_, err := s.Orders.Cancel(context.Background(), order.ID)
+	expectError(t, err, ErrInvalidTransition)
+	checkStockLevels(t, s, product, 6, 0)
+}
+
+func TestUpdateStatusRejectsInvalidTransition(t *testing.T) {
+	s := newTestServices(t)
+	product := createProduct(t, s, "Polera", 1000, models.ProductInput{})
+	order := checkout(t, s, product, 1)
+
+	_, err := s.Orders.UpdateStatus(context.Background(), order.ID, models.OrderShipped)
+	expectError(t, err, ErrInvalidTransition)
+
+	_, err = s.Orders.UpdateStatus(context.Background(), order.ID, "lost")
+	expectError(t, err, ErrInvalidStatus)
+}
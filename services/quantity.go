@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+)
+
+var ErrInvalidQuantity = apperrors.New(apperrors.CodeInvalidQuantity, "invalid quantity")
+
+// quantityLimits devuelve los límites de cantidad del producto. Los valores
+// menores que 1 equivalen a no tener mínimo ni múltiplo.
+func quantityLimits(product models.Product) (min, max, step int) {
+	min, max, step = product.MinQuantity, product.MaxQuantity, product.QuantityStep
+	if min < 1 {
+		min = 1
+	}
+	if step < 1 {
+		step = 1
+	}
+	return min, max, step
+}
+
+// invalidQuantity arma el error de cantidad con los límites del producto,
+// para que el cliente pueda corregir la cantidad.
+func invalidQuantity(product models.Product, quantity int, reason string) error {
+	min, max, step := quantityLimits(product)
+	return ErrInvalidQuantity.With(
+		fmt.Sprintf("invalid quantity %d for product %s: %s", quantity, product.Name, reason),
+		apperrors.Details{
+			"productID": product.ID,
+			"quantity":  quantity,
+			"min":       min,
+			"max":       max,
+			"step":      step,
+		},
+	)
+}
+
+// checkPositive verifica que se agreguen unidades al carrito.
+func checkPositive(product models.Product, quantity int) error {
+	if quantity <= 0 {
+		return invalidQuantity(product, quantity, "quantity must be positive")
+	}
+	return nil
+}
+
+// checkQuantity verifica que quantity, la cantidad que quedará en la línea del
+// carrito, respete los límites del producto.
+func checkQuantity(product models.Product, quantity int) error {
+	min, max, step := quantityLimits(product)
+	switch {
+	case quantity <= 0:
+		return invalidQuantity(product, quantity, "quantity must be positive")
+	case quantity < min:
+		return invalidQuantity(product, quantity, fmt.Sprintf("at least %d units are required", min))
+	case max > 0 && quantity > max:
+		return invalidQuantity(product, quantity, fmt.Sprintf("at most %d units are allowed", max))
+	case quantity%step != 0:
+		return invalidQuantity(product, quantity, fmt.Sprintf("quantity must be a multiple of %d", step))
+	}
+	return nil
+}
+
+// applyQuantityLimits valida los límites de cantidad recibidos y los copia
+// sobre el producto.
+func applyQuantityLimits(product *models.Product, input models.ProductInput) error {
+	if input.MinQuantity != nil {
+		if *input.MinQuantity < 1 {
+			return fmt.Errorf("%w: min_quantity must be at least 1", ErrInvalidProduct)
+		}
+		product.MinQuantity = *input.MinQuantity
+	}
+	if input.MaxQuantity != nil {
+		if *input.MaxQuantity < 0 {
+			return fmt.Errorf("%w: max_quantity must not be negative", ErrInvalidProduct)
+		}
+		product.MaxQuantity = *input.MaxQuantity
+	}
+	if input.QuantityStep != nil {
+		if *input.QuantityStep < 1 {
+			return fmt.Errorf("%w: quantity_step must be at least 1", ErrInvalidProduct)
+		}
+		product.QuantityStep = *input.QuantityStep
+	}
+
+	min, max, _ := quantityLimits(*product)
+	if max > 0 && max < min {
+		return fmt.Errorf("%w: max_quantity must not be lower than min_quantity", ErrInvalidProduct)
+	}
+	return nil
+}
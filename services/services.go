@@ -0,0 +1,37 @@
+// Package services contiene las reglas de negocio del carrito. Los handlers
+// REST y los patterns de RabbitMQ llaman a los mismos servicios, por lo que
+// ambos transportes se comportan igual.
+package services
+
+import (
+	"errors"
+
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+// Services agrupa los servicios del dominio sobre un mismo Store.
+type Services struct {
+	Catalog *CatalogService
+	Users   *UserService
+	Cart    *CartService
+	Orders  *OrderService
+}
+
+// New crea los servicios sobre store.
+func New(store repository.Store) *Services {
+	return &Services{
+		Catalog: &CatalogService{store: store},
+		Users:   &UserService{store: store},
+		Cart:    &CartService{store: store},
+		Orders:  &OrderService{store: store},
+	}
+}
+
+// notFound traduce repository.ErrNotFound al error centinela del recurso
+// buscado y deja pasar cualquier otro error.
+func notFound(err error, sentinel error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return sentinel
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// newTestServices crea los servicios sobre un store en memoria vacío.
+func newTestServices(t *testing.T) *Services {
+	t.Helper()
+	return New(repository.NewMemoryStore())
+}
+
+// createProduct crea un producto activo con el precio dado. input permite
+// cambiar el resto de los campos.
+func createProduct(t *testing.T, s *Services, name string, price int64, input models.ProductInput) models.Product {
+	t.Helper()
+	input.Name = ptr(name)
+	input.Price = ptr(price)
+	product, err := s.Catalog.CreateProduct(context.Background(), input)
+	if err != nil {
+		t.Fatalf("CreateProduct(%s): %v", name, err)
+	}
+	return product
+}
+
+func createUser(t *testing.T, s *Services, username string) models.User {
+	t.Helper()
+	user, err := s.Users.Create(context.Background(), username)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", username, err)
+	}
+	return user
+}
+
+func setStock(t *testing.T, s *Services, product models.Product, available int) {
+	t.Helper()
+	if _, err := s.Catalog.SetInventory(context.Background(), product.Name, available); err != nil {
+		t.Fatalf("SetInventory(%s): %v", product.Name, err)
+	}
+}
+
+func addToCartOK(t *testing.T, s *Services, user models.User, product models.Product, variant string, quantity int) models.CartItem {
+	t.Helper()
+	item, err := s.Cart.AddProduct(context.Background(), user.ID, product.ID, variant, quantity)
+	if err != nil {
+		t.Fatalf("AddProduct(%s, %d): %v", product.Name, quantity, err)
+	}
+	return item
+}
+
+func cartOf(t *testing.T, s *Services, user models.User) []models.CartItem {
+	t.Helper()
+	found, err := s.Users.ByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("ByID(%d): %v", user.ID, err)
+	}
+	return found.Cart
+}
+
+func stockOf(t *testing.T, s *Services, product models.Product) models.Inventory {
+	t.Helper()
+	inventory, err := s.Catalog.Inventory(context.Background(), product.Name)
+	if err != nil {
+		t.Fatalf("Inventory(%s): %v", product.Name, err)
+	}
+	return inventory
+}
+
+func checkStockLevels(t *testing.T, s *Services, product models.Product, available, reserved int) {
+	t.Helper()
+	inventory := stockOf(t, s, product)
+	if inventory.Available != available || inventory.Reserved != reserved {
+		t.Errorf("stock of %s = %d available, %d reserved; want %d, %d",
+			product.Name, inventory.Available, inventory.Reserved, available, reserved)
+	}
+}
+
+func expectError(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("error = %v; want %v", err, want)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+var (
+	ErrInsufficientStock = apperrors.New(apperrors.CodeInsufficientStock, "insufficient stock")
+	ErrInvalidStock      = apperrors.New(apperrors.CodeInvalidStock, "available stock must not be negative")
+)
+
+// insufficientStock arma el error de falta de stock con los datos del
+// producto, para que el cliente pueda ajustar la cantidad.
+func insufficientStock(productID uint, requested, available int) error {
+	return ErrInsufficientStock.With(
+		fmt.Sprintf("insufficient stock: product %d requested %d, available %d", productID, requested, available),
+		apperrors.Details{"productID": productID, "requested": requested, "available": available},
+	)
+}
+
+// quantitiesByProduct agrupa las cantidades de las líneas por producto.
+func quantitiesByProduct(lines []models.OrderLine) (map[uint]int, []uint) {
+	quantities := make(map[uint]int, len(lines))
+	for _, line := range lines {
+		quantities[line.ProductID] += line.Quantity
+	}
+
+	// Se bloquean las filas siempre en el mismo orden para evitar deadlocks
+	// entre checkouts concurrentes
+	ids := make([]uint, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return quantities, ids
+}
+
+// lockInventories bloquea hasta el fin de la transacción las filas de
+// inventario de los productos dados. Los productos sin inventario no aparecen
+// en el mapa.
+func lockInventories(tx repository.Store, productIDs []uint) (map[uint]*models.Inventory, error) {
+	inventories, err := tx.Inventory().LockByProductIDs(productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byProduct := make(map[uint]*models.Inventory, len(inventories))
+	for i := range inventories {
+		byProduct[inventories[i].ProductID] = &inventories[i]
+	}
+	return byProduct, nil
+}
+
+// reserveStock descuenta del stock disponible las cantidades de las líneas y
+// las marca como reservadas. Debe ejecutarse dentro de la transacción de la orden.
+func reserveStock(tx repository.Store, lines []models.OrderLine) error {
+	quantities, ids := quantitiesByProduct(lines)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	inventories, err := lockInventories(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		inventory, tracked := inventories[id]
+		if !tracked {
+			continue
+		}
+		quantity := quantities[id]
+		if inventory.Available < quantity {
+			return insufficientStock(id, quantity, inventory.Available)
+		}
+		inventory.Available -= quantity
+		inventory.Reserved += quantity
+		if err := tx.Inventory().Save(inventory); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseStock devuelve al stock disponible las unidades reservadas por las
+// líneas de una orden cancelada.
+func releaseStock(tx repository.Store, lines []models.OrderLine) error {
+	quantities, ids := quantitiesByProduct(lines)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	inventories, err := lockInventories(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		inventory, tracked := inventories[id]
+		if !tracked {
+			continue
+		}
+		quantity := quantities[id]
+		inventory.Available += quantity
+		inventory.Reserved -= quantity
+		if inventory.Reserved < 0 {
+			inventory.Reserved = 0
+		}
+		if err := tx.Inventory().Save(inventory); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commitStock descuenta de las unidades reservadas las líneas de una orden
+// despachada; el stock disponible ya se había descontado al reservar.
+func commitStock(tx repository.Store, lines []models.OrderLine) error {
+	quantities, ids := quantitiesByProduct(lines)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	inventories, err := lockInventories(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		inventory, tracked := inventories[id]
+		if !tracked {
+			continue
+		}
+		inventory.Reserved -= quantities[id]
+		if inventory.Reserved < 0 {
+			inventory.Reserved = 0
+		}
+		if err := tx.Inventory().Save(inventory); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkStock verifica que haya stock disponible para la cantidad total que
+// quedará en el carrito. No reserva unidades: la reserva ocurre en el checkout.
+func checkStock(tx repository.Store, productID uint, quantity int) error {
+	inventory, err := tx.Inventory().FindByProductID(productID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if inventory.Available < quantity {
+		return insufficientStock(productID, quantity, inventory.Available)
+	}
+	return nil
+}
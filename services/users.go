@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/FelipeGeraldoblufus/Cart/apperrors"
+	"github.com/FelipeGeraldoblufus/Cart/models"
+	"github.com/FelipeGeraldoblufus/Cart/repository"
+)
+
+var (
+	ErrUserNotFound      = apperrors.New(apperrors.CodeUserNotFound, "user not found")
+	ErrDuplicateUsername = apperrors.New(apperrors.CodeDuplicateUsername, "username already exists")
+	ErrUsernameRequired  = apperrors.New(apperrors.CodeInvalidRequest, "Username is required")
+)
+
+// UserService administra los usuarios. Cada usuario se devuelve con su
+// carrito y los productos de cada línea cargados.
+type UserService struct {
+	store repository.Store
+}
+
+func (s *UserService) ByUsername(ctx context.Context, username string) (models.User, error) {
+	user, err := s.store.WithContext(ctx).Users().FindByUsername(username)
+	return user, notFound(err, ErrUserNotFound)
+}
+
+func (s *UserService) ByID(ctx context.Context, userID uint) (models.User, error) {
+	user, err := s.store.WithContext(ctx).Users().FindByID(userID)
+	return user, notFound(err, ErrUserNotFound)
+}
+
+// Create crea el usuario con el carrito vacío.
+func (s *UserService) Create(ctx context.Context, username string) (models.User, error) {
+	if strings.TrimSpace(username) == "" {
+		return models.User{}, ErrUsernameRequired
+	}
+
+	newUser := models.User{
+		Username: username,
+		Cart:     []models.CartItem{},
+	}
+
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		// Verifica si el nombre de usuario ya existe en la base de datos
+		if _, err := tx.Users().FindByUsername(username); err == nil {
+			return ErrDuplicateUsername
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		err := tx.Users().Create(&newUser)
+		if errors.Is(err, repository.ErrDuplicatedKey) {
+			return ErrDuplicateUsername
+		}
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return newUser, nil
+}
+
+// Rename cambia el nombre de usuario y encola el evento correspondiente.
+func (s *UserService) Rename(ctx context.Context, currentUsername, newUsername string) (models.User, error) {
+	if strings.TrimSpace(newUsername) == "" {
+		return models.User{}, ErrUsernameRequired
+	}
+
+	var existingUser models.User
+
+	err := s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		// Buscar el usuario actual en la base de datos
+		var err error
+		existingUser, err = tx.Users().FindByUsername(currentUsername)
+		if err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+
+		// Modificar el nombre de usuario
+		existingUser.Username = newUsername
+
+		if err := tx.Users().Save(&existingUser); err != nil {
+			if errors.Is(err, repository.ErrDuplicatedKey) {
+				return ErrDuplicateUsername
+			}
+			return err
+		}
+		return enqueueEvent(tx, models.EventUserRenamed, models.UserRenamedEvent{
+			UserID:      existingUser.ID,
+			OldUsername: currentUsername,
+			NewUsername: newUsername,
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return existingUser, nil
+}
+
+func (s *UserService) Delete(ctx context.Context, username string) error {
+	return s.store.WithContext(ctx).Transaction(func(tx repository.Store) error {
+		user, err := tx.Users().FindByUsername(username)
+		if err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+
+		return tx.Users().Delete(&user)
+	})
+}
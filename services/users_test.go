@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	s := newTestServices(t)
+	createUser(t, s, "ana")
+	createUser(t, s, "bea")
+
+	tests := []struct {
+		name        string
+		newUsername string
+		want        error
+	}{
+		{"empty", "", ErrUsernameRequired},
+		{"whitespace", "  ", ErrUsernameRequired},
+		{"taken", "bea", ErrDuplicateUsername},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Users.Rename(context.Background(), "ana", tt.newUsername)
+			expectError(t, err, tt.want)
+		})
+	}
+
+	user, err := s.Users.Rename(context.Background(), "ana", "carla")
+	if err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if user.Username != "carla" {
+		t.Errorf("username = %q; want carla", user.Username)
+	}
+	_, err = s.Users.ByUsername(context.Background(), "ana")
+	expectError(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,109 @@
+// Package tracing configura OpenTelemetry y propaga el contexto de traza W3C
+// entre HTTP, RabbitMQ y la base de datos.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/FelipeGeraldoblufus/Cart"
+
+// serviceName es el nombre del servicio en las trazas si no se define
+// OTEL_SERVICE_NAME.
+const serviceName = "cart-service"
+
+// Tracer devuelve el tracer del servicio.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// exporterName devuelve el exporter elegido con OTEL_TRACES_EXPORTER: otlp,
+// stdout (o console) o none. Sin la variable se usa otlp si hay un endpoint
+// configurado y, si no, none.
+func exporterName() string {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if name != "" {
+		return name
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return "otlp"
+	}
+	return "none"
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "otlp":
+		// El endpoint, los headers y el resto de las opciones se leen de las
+		// variables OTEL_EXPORTER_OTLP_*
+		return otlptracehttp.New(ctx)
+	case "stdout", "console":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+	return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", name)
+}
+
+// Setup configura el propagador W3C (traceparent, tracestate y baggage) y el
+// exporter de trazas. Con el exporter none el contexto igual se propaga, pero
+// las trazas no se envían. Devuelve una función que exporta las trazas
+// pendientes al apagar el servicio.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	name := exporterName()
+	if name == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME y OTEL_RESOURCE_ATTRIBUTES reemplazan los valores por
+	// defecto
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Inject devuelve el contexto de traza de ctx como un mapa, para guardarlo
+// junto a un evento que se publica más tarde.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract agrega a ctx el contexto de traza guardado con Inject.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}